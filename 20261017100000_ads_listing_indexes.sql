@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE INDEX IF NOT EXISTS idx_ads_created_at ON ads (created_at DESC, id DESC);
+CREATE INDEX IF NOT EXISTS idx_ads_category_id ON ads (category_id);
+CREATE INDEX IF NOT EXISTS idx_ads_user_id ON ads (user_id);
+CREATE INDEX IF NOT EXISTS idx_ads_price ON ads (price);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_ads_price;
+DROP INDEX IF EXISTS idx_ads_user_id;
+DROP INDEX IF EXISTS idx_ads_category_id;
+DROP INDEX IF EXISTS idx_ads_created_at;
@@ -0,0 +1,7 @@
+-- +goose Up
+-- created_at входит в ключ курсора ленты, поэтому не может быть NULL
+UPDATE ads SET created_at = NOW() WHERE created_at IS NULL;
+ALTER TABLE ads ALTER COLUMN created_at SET NOT NULL;
+
+-- +goose Down
+ALTER TABLE ads ALTER COLUMN created_at DROP NOT NULL;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- Полнотекстовый индекс по заголовку и описанию на русском и английском.
+-- Заголовок имеет больший вес (A), чем описание (B).
+ALTER TABLE ads ADD COLUMN IF NOT EXISTS search_vector tsvector
+    GENERATED ALWAYS AS (
+        setweight(to_tsvector('russian', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('english', coalesce(title, '')), 'A') ||
+        setweight(to_tsvector('russian', coalesce(description, '')), 'B') ||
+        setweight(to_tsvector('english', coalesce(description, '')), 'B')
+    ) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_ads_search_vector ON ads USING GIN (search_vector);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_ads_search_vector;
+ALTER TABLE ads DROP COLUMN IF EXISTS search_vector;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS ad_images(
+    id SERIAL PRIMARY KEY,
+    ad_id INT NOT NULL REFERENCES ads(id) ON DELETE CASCADE,
+    filename VARCHAR(255) NOT NULL,
+    position INT NOT NULL,
+    is_cover BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_ad_images_ad_id ON ad_images (ad_id, position);
+-- У объявления может быть только одна обложка
+CREATE UNIQUE INDEX IF NOT EXISTS idx_ad_images_cover ON ad_images (ad_id) WHERE is_cover;
+
+-- Переносим существующие изображения; ads.image_filename остается именем файла обложки
+INSERT INTO ad_images (ad_id, filename, position, is_cover)
+SELECT id, image_filename, 0, TRUE FROM ads WHERE image_filename <> '';
+
+-- +goose Down
+DROP TABLE IF EXISTS ad_images;
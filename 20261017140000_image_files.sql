@@ -0,0 +1,15 @@
+-- +goose Up
+-- Файлы изображений хранятся под именем, производным от хеша содержимого,
+-- поэтому один файл может использоваться несколькими объявлениями.
+-- ref_count - число строк ad_images, ссылающихся на файл.
+CREATE TABLE IF NOT EXISTS image_files(
+    filename VARCHAR(255) PRIMARY KEY,
+    ref_count INT NOT NULL CHECK (ref_count > 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO image_files (filename, ref_count)
+SELECT filename, COUNT(*) FROM ad_images GROUP BY filename;
+
+-- +goose Down
+DROP TABLE IF EXISTS image_files;
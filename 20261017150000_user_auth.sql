@@ -0,0 +1,33 @@
+-- +goose Up
+-- У пользователей, созданных до появления паролей, хеш пустой: войти они
+-- не смогут, пока пароль не будет задан
+ALTER TABLE users ADD COLUMN IF NOT EXISTS password_hash VARCHAR(255) NOT NULL DEFAULT '';
+
+-- Сессия создается при входе и живет, пока обновляется refresh-токен.
+-- Access-токены содержат ID сессии, поэтому отзыв сессии сразу их блокирует.
+CREATE TABLE IF NOT EXISTS auth_sessions(
+    id UUID PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    revoked_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_auth_sessions_user_id ON auth_sessions (user_id);
+
+-- Refresh-токены одноразовые: при обновлении старый помечается использованным
+-- и выдается новый. Повторное предъявление использованного токена отзывает сессию.
+CREATE TABLE IF NOT EXISTS refresh_tokens(
+    id SERIAL PRIMARY KEY,
+    session_id UUID NOT NULL REFERENCES auth_sessions(id) ON DELETE CASCADE,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_session_id ON refresh_tokens (session_id);
+
+-- +goose Down
+DROP TABLE IF EXISTS refresh_tokens;
+DROP TABLE IF EXISTS auth_sessions;
+ALTER TABLE users DROP COLUMN IF EXISTS password_hash;
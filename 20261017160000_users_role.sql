@@ -0,0 +1,7 @@
+-- +goose Up
+-- Роль пользователя: admin может изменять и удалять любые объявления
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'admin'));
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS role;
@@ -0,0 +1,76 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS roles(
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    description VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS permissions(
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE TABLE IF NOT EXISTS role_permissions(
+    role_id INT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id INT NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_roles(
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id INT NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    PRIMARY KEY (user_id, role_id)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('user', 'Управляет своими объявлениями'),
+    ('moderator', 'Может выключать любые объявления'),
+    ('admin', 'Полный доступ: категории, пользователи, роли')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO permissions (name, description) VALUES
+    ('ads:write', 'Создание и изменение своих объявлений'),
+    ('ads:moderate', 'Включение и выключение любых объявлений'),
+    ('ads:manage', 'Изменение и удаление любых объявлений'),
+    ('categories:manage', 'Управление категориями'),
+    ('users:manage', 'Создание и удаление пользователей'),
+    ('roles:manage', 'Назначение ролей')
+ON CONFLICT (name) DO NOTHING;
+
+-- Роли не наследуются, поэтому каждая содержит полный набор своих прав
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id
+FROM roles r
+JOIN permissions p ON (r.name, p.name) IN (
+    ('user', 'ads:write'),
+    ('moderator', 'ads:write'),
+    ('moderator', 'ads:moderate'),
+    ('admin', 'ads:write'),
+    ('admin', 'ads:moderate'),
+    ('admin', 'ads:manage'),
+    ('admin', 'categories:manage'),
+    ('admin', 'users:manage'),
+    ('admin', 'roles:manage')
+)
+ON CONFLICT DO NOTHING;
+
+-- Переносим роли из users.role
+INSERT INTO user_roles (user_id, role_id)
+SELECT u.id, r.id FROM users u JOIN roles r ON r.name = u.role
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+
+-- +goose Down
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(20) NOT NULL DEFAULT 'user'
+    CHECK (role IN ('user', 'admin'));
+
+UPDATE users u SET role = 'admin'
+FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+WHERE ur.user_id = u.id AND r.name = 'admin';
+
+DROP TABLE IF EXISTS user_roles;
+DROP TABLE IF EXISTS role_permissions;
+DROP TABLE IF EXISTS permissions;
+DROP TABLE IF EXISTS roles;
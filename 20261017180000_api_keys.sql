@@ -0,0 +1,33 @@
+-- +goose Up
+-- Ключ показывается один раз при создании; в базе хранится только SHA-256.
+-- prefix - открытая часть ключа для поиска записи без перебора хешей.
+CREATE TABLE IF NOT EXISTS api_keys(
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    prefix VARCHAR(16) NOT NULL UNIQUE,
+    key_hash CHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL,
+    expires_at TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+
+CREATE TABLE IF NOT EXISTS api_key_audit_log(
+    id BIGSERIAL PRIMARY KEY,
+    api_key_id INT NOT NULL REFERENCES api_keys(id) ON DELETE CASCADE,
+    method VARCHAR(10) NOT NULL,
+    path TEXT NOT NULL,
+    status INT NOT NULL,
+    ip VARCHAR(64) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_api_key_audit_log_key ON api_key_audit_log (api_key_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS api_key_audit_log;
+DROP TABLE IF EXISTS api_keys;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- Email считается подтвержденным, пока не изменится; у существующих
+-- пользователей он не подтвержден
+ALTER TABLE users ADD COLUMN IF NOT EXISTS email_verified_at TIMESTAMPTZ;
+
+-- Одноразовые токены из писем. Хранится только SHA-256 токена. Токен
+-- привязан к адресу, на который отправлен: после смены email он недействителен.
+CREATE TABLE IF NOT EXISTS email_tokens(
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose VARCHAR(32) NOT NULL CHECK (purpose IN ('email_verification', 'password_reset')),
+    email VARCHAR(150) NOT NULL,
+    token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_email_tokens_user_id ON email_tokens (user_id, purpose);
+
+-- +goose Down
+DROP TABLE IF EXISTS email_tokens;
+ALTER TABLE users DROP COLUMN IF EXISTS email_verified_at;
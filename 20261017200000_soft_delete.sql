@@ -0,0 +1,24 @@
+-- +goose Up
+-- Удаленные пользователи и объявления остаются в базе до истечения срока
+-- хранения, после чего удаляются окончательно фоновой очисткой
+ALTER TABLE users ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+ALTER TABLE ads ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ;
+
+-- Email удаленного пользователя можно занять заново; восстановить такого
+-- пользователя нельзя, пока адрес занят
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_active ON users (email) WHERE deleted_at IS NULL;
+
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_ads_deleted_at ON ads (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+-- Перед откатом удаленные строки стираются, иначе вернуть уникальность email нельзя
+DELETE FROM ads WHERE deleted_at IS NOT NULL;
+DELETE FROM users WHERE deleted_at IS NOT NULL;
+DROP INDEX IF EXISTS idx_ads_deleted_at;
+DROP INDEX IF EXISTS idx_users_deleted_at;
+DROP INDEX IF EXISTS idx_users_email_active;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+ALTER TABLE ads DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE users DROP COLUMN IF EXISTS deleted_at;
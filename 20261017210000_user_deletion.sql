@@ -0,0 +1,17 @@
+-- +goose Up
+-- Политика удаления пользователя применяется в коде, поэтому внешний ключ
+-- объявлений явно запрещает удалять пользователя, у которого остались
+-- объявления: окончательная очистка сначала удаляет их в той же транзакции
+ALTER TABLE ads DROP CONSTRAINT IF EXISTS ads_user_id_fkey;
+ALTER TABLE ads ADD CONSTRAINT ads_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE RESTRICT;
+
+-- Обезличенный пользователь остается владельцем своих объявлений,
+-- но его данные стерты, и восстановить его нельзя
+ALTER TABLE users ADD COLUMN IF NOT EXISTS anonymized_at TIMESTAMPTZ;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN IF EXISTS anonymized_at;
+ALTER TABLE ads DROP CONSTRAINT IF EXISTS ads_user_id_fkey;
+ALTER TABLE ads ADD CONSTRAINT ads_user_id_fkey
+    FOREIGN KEY (user_id) REFERENCES users(id);
@@ -0,0 +1,28 @@
+-- +goose Up
+-- Задания на выгрузку и стирание данных пользователя. Таблица служит и журналом
+-- запросов, поэтому user_id и requested_by не ссылаются на users: записи
+-- остаются после окончательного удаления пользователя.
+CREATE TABLE IF NOT EXISTS user_data_jobs(
+    id BIGSERIAL PRIMARY KEY,
+    kind VARCHAR(16) NOT NULL CHECK (kind IN ('export', 'erasure')),
+    user_id INT NOT NULL,
+    requested_by INT NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'running', 'done', 'failed')),
+    error TEXT NOT NULL DEFAULT '',
+    -- result_key - имя файла выгрузки; очищается, когда срок хранения истек
+    result_key TEXT,
+    result_expires_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at TIMESTAMPTZ,
+    finished_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_data_jobs_user_id ON user_data_jobs (user_id, id DESC);
+CREATE INDEX IF NOT EXISTS idx_user_data_jobs_pending ON user_data_jobs (id) WHERE status = 'pending';
+
+-- Одновременно у пользователя может быть только одно незавершенное задание каждого вида
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_data_jobs_active
+    ON user_data_jobs (user_id, kind) WHERE status IN ('pending', 'running');
+
+-- +goose Down
+DROP TABLE IF EXISTS user_data_jobs;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Начальный набор категорий вместо маршрута GET /init-categories. Категории
+-- для конкретного окружения добавляются командой seed-categories.
+INSERT INTO categories (name, extra_property)
+VALUES
+    ('Electronics', 'Color: Black'),
+    ('Clothing', 'Size: M')
+ON CONFLICT (name) DO NOTHING;
+
+-- +goose Down
+-- Удаляются только категории без объявлений
+DELETE FROM categories c
+WHERE c.name IN ('Electronics', 'Clothing')
+  AND NOT EXISTS (SELECT 1 FROM ads a WHERE a.category_id = c.id);
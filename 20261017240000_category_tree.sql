@@ -0,0 +1,12 @@
+-- +goose Up
+-- Дерево категорий: parent_id пуст у категорий верхнего уровня. Циклы
+-- длиннее одного шага запрещает код при переносе категории.
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES categories(id) ON DELETE RESTRICT;
+ALTER TABLE categories ADD CONSTRAINT categories_parent_not_self CHECK (parent_id <> id);
+
+CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories (parent_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_categories_parent_id;
+ALTER TABLE categories DROP CONSTRAINT IF EXISTS categories_parent_not_self;
+ALTER TABLE categories DROP COLUMN IF EXISTS parent_id;
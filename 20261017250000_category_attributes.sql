@@ -0,0 +1,33 @@
+-- +goose Up
+-- Схема атрибутов объявлений категории заменяет свободное поле
+-- extra_property: [{"name": "size", "type": "enum", "enum": ["S", "M"], "required": true}].
+-- Значения атрибутов объявления хранятся в ads.attributes и проверяются
+-- по схеме при создании и изменении объявления.
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '[]';
+
+-- Имя из extra_property вида "Color: Black" становится необязательным
+-- строковым атрибутом color
+UPDATE categories
+SET attributes = jsonb_build_array(jsonb_build_object('name', n.name, 'type', 'string', 'required', false))
+FROM (
+    SELECT id, trim(BOTH '_' FROM regexp_replace(lower(trim(split_part(extra_property, ':', 1))), '[^a-z0-9]+', '_', 'g')) AS name
+    FROM categories
+) n
+WHERE categories.id = n.id AND n.name ~ '^[a-z][a-z0-9_]{0,49}$';
+
+ALTER TABLE categories DROP COLUMN IF EXISTS extra_property;
+
+ALTER TABLE ads ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}';
+
+-- Фильтры attr.<имя>=значение проверяют вхождение (@>)
+CREATE INDEX IF NOT EXISTS idx_ads_attributes ON ads USING GIN (attributes jsonb_path_ops);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_ads_attributes;
+ALTER TABLE ads DROP COLUMN IF EXISTS attributes;
+
+-- Восстанавливается только имя первого атрибута
+ALTER TABLE categories ADD COLUMN IF NOT EXISTS extra_property VARCHAR(100) NOT NULL DEFAULT '';
+UPDATE categories SET extra_property = left(attributes->0->>'name', 100) WHERE jsonb_array_length(attributes) > 0;
+ALTER TABLE categories ALTER COLUMN extra_property DROP DEFAULT;
+ALTER TABLE categories DROP COLUMN IF EXISTS attributes;
@@ -1,34 +1,34 @@
--- +goose Up
--- create database golang_db;
-
-CREATE TABLE IF NOT EXISTS users(
-    id SERIAL PRIMARY KEY,
-    name VARCHAR(100) NOT NULL,
-    email VARCHAR(150) NOT NULL UNIQUE,
-    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
-);
-
-CREATE TABLE IF NOT EXISTS categories(
-    id SERIAL PRIMARY KEY,
-    name VARCHAR(50) NOT NULL UNIQUE,
-    extra_property VARCHAR(100) NOT NULL
-);
-
-CREATE TABLE IF NOT EXISTS ads(
-    id SERIAL PRIMARY KEY,
-    user_id INT REFERENCES users(id) NOT NULL,
-    category_id INT REFERENCES categories(id) NOT NULL,
-    title VARCHAR(200) NOT NULL,
-    description TEXT NOT NULL,
-    price DECIMAL(10, 2) NOT NULL,
-    image_filename VARCHAR(255) NOT NULL,
-    is_enabled BOOLEAN NOT NULL DEFAULT TRUE,
-    created_at TIMESTAMPTZ DEFAULT NOW()
-);
-
--- +goose Down
-DROP TABLE IF EXISTS ads;
-DROP TABLE IF EXISTS categories;
-DROP TABLE IF EXISTS users;
-
--- drop database golang_db;
+-- +goose Up
+-- create database golang_db;
+
+CREATE TABLE IF NOT EXISTS users(
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    email VARCHAR(150) NOT NULL UNIQUE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS categories(
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,
+    extra_property VARCHAR(100) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS ads(
+    id SERIAL PRIMARY KEY,
+    user_id INT REFERENCES users(id) NOT NULL,
+    category_id INT REFERENCES categories(id) NOT NULL,
+    title VARCHAR(200) NOT NULL,
+    description TEXT NOT NULL,
+    price DECIMAL(10, 2) NOT NULL,
+    image_filename VARCHAR(255) NOT NULL,
+    is_enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMPTZ DEFAULT NOW()
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS ads;
+DROP TABLE IF EXISTS categories;
+DROP TABLE IF EXISTS users;
+
+-- drop database golang_db;
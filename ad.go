@@ -1,15 +1,20 @@
-package models
-
-import "time"
-
-type Ad struct {
-	ID          int       `json:"id"`
-	User        User      `json:"user"`
-	Category    Category  `json:"category"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Price       float64   `json:"price"`
-	Image       string    `json:"image"`
-	IsEnabled   bool      `json:"is_enabled"`
-	CreatedAt   time.Time `json:"created_at"`
-}
+package models
+
+import "time"
+
+type Ad struct {
+	ID          int       `json:"id"`
+	User        User      `json:"user"`
+	Category    Category  `json:"category"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Price       float64   `json:"price"`
+	Image       string    `json:"image"`
+	Images      []AdImage `json:"images"`
+	// Attributes - значения атрибутов по схеме категории
+	Attributes AdAttributes `json:"attributes"`
+	IsEnabled  bool         `json:"is_enabled"`
+	CreatedAt  time.Time    `json:"created_at"`
+	// DeletedAt заполнен только у удаленных объявлений, которые еще можно восстановить
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AdCursor указывает позицию в ленте объявлений, отсортированной по (created_at, id)
+type AdCursor struct {
+	CreatedAt time.Time
+	ID        int
+	// Backward означает движение к более новым объявлениям (предыдущая страница)
+	Backward bool
+}
+
+type adCursorPayload struct {
+	CreatedAt time.Time `json:"t"`
+	ID        int       `json:"id"`
+	Backward  bool      `json:"b,omitempty"`
+}
+
+// Encode возвращает непрозрачное строковое представление курсора
+func (c AdCursor) Encode() string {
+	data, _ := json.Marshal(adCursorPayload{CreatedAt: c.CreatedAt, ID: c.ID, Backward: c.Backward})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeAdCursor разбирает курсор, полученный от клиента
+func DecodeAdCursor(s string) (*AdCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	var payload adCursorPayload
+	if err := json.Unmarshal(data, &payload); err != nil || payload.ID <= 0 || payload.CreatedAt.IsZero() {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+
+	return &AdCursor{CreatedAt: payload.CreatedAt, ID: payload.ID, Backward: payload.Backward}, nil
+}
@@ -0,0 +1,28 @@
+package models
+
+// AdFacets - счетчики объявлений для боковой панели фильтров каталога.
+// Каждый фасет считается с учетом всех активных фильтров, кроме собственного,
+// чтобы в интерфейсе были видны альтернативные значения.
+type AdFacets struct {
+	Categories   []CategoryFacet    `json:"categories"`
+	PriceBuckets []PriceBucketFacet `json:"price_buckets"`
+	Status       StatusFacet        `json:"status"`
+}
+
+type CategoryFacet struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+// PriceBucketFacet - ценовой диапазон [Min, Max); у последнего диапазона Max не задан
+type PriceBucketFacet struct {
+	Min   float64  `json:"min"`
+	Max   *float64 `json:"max"`
+	Count int      `json:"count"`
+}
+
+type StatusFacet struct {
+	Enabled  int `json:"enabled"`
+	Disabled int `json:"disabled"`
+}
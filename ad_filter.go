@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// AdFilter описывает параметры выборки списка объявлений
+type AdFilter struct {
+	Page          int
+	Limit         int
+	CategoryID    *int
+	UserID        *int
+	IsEnabled     *bool
+	PriceMin      *float64
+	PriceMax      *float64
+	CreatedAfter  *time.Time
+	CreatedBefore *time.Time
+	SortBy        string
+	SortOrder     string
+	// Cursor включает keyset-пагинацию вместо постраничной
+	Cursor *AdCursor
+	// Attributes - условия на атрибуты объявления из параметров attr.<имя>
+	Attributes []AttributeFilter
+	// Deleted выбирает удаленные объявления вместо действующих
+	Deleted bool
+}
+
+// Offset возвращает смещение для текущей страницы
+func (f AdFilter) Offset() int {
+	return (f.Page - 1) * f.Limit
+}
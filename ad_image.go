@@ -0,0 +1,31 @@
+package models
+
+// MaxAdImages - максимальное количество изображений у одного объявления
+const MaxAdImages = 10
+
+type AdImage struct {
+	ID       int           `json:"id"`
+	Filename string        `json:"-"`
+	URL      string        `json:"url"`
+	Variants ImageVariants `json:"variants"`
+	Position int           `json:"position"`
+	IsCover  bool          `json:"is_cover"`
+}
+
+// ImageVariants - ссылки на уменьшенные копии изображения в JPEG
+// и те же копии в WebP, если сервер собран с поддержкой WebP
+type ImageVariants struct {
+	VariantURLs
+	WebP *VariantURLs `json:"webp,omitempty"`
+}
+
+// VariantURLs - ссылки на варианты изображения одного формата
+type VariantURLs struct {
+	Thumb  string `json:"thumb"`
+	Medium string `json:"medium"`
+	Large  string `json:"large"`
+}
+
+type AdImagesOrder struct {
+	ImageIDs []int `json:"image_ids" binding:"required,min=1"`
+}
@@ -0,0 +1,417 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"golang-test/internal/imaging"
+	"golang-test/internal/models"
+	"golang-test/internal/storage"
+)
+
+// ErrImageFileMissing возвращается, если загруженный файл удалили до того,
+// как его успели привязать к объявлению: в это время другое объявление
+// освободило последнюю ссылку на такое же изображение. Файл нужно загрузить заново.
+var ErrImageFileMissing = errors.New("image file was removed before it was saved, upload it again")
+
+// lockImageFiles блокирует файлы изображений до конца транзакции. Привязка
+// файла к объявлению, освобождение ссылки и удаление файла без ссылок
+// выполняются под этой блокировкой и не пересекаются; сборщик мусора
+// (imagegc) берет ту же блокировку перед удалением. Файлы блокируются
+// в порядке имен, чтобы транзакции не ждали друг друга по кругу.
+func lockImageFiles(ctx context.Context, tx *sql.Tx, filenames []string) error {
+	sorted := slices.Clone(filenames)
+	slices.Sort(sorted)
+	for _, filename := range slices.Compact(sorted) {
+		if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", filename); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queryer - общий интерфейс *sql.DB и *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadAdImages возвращает изображения объявлений, сгруппированные по ID объявления
+func loadAdImages(ctx context.Context, q queryer, adIDs []int) (map[int][]models.AdImage, error) {
+	images := make(map[int][]models.AdImage, len(adIDs))
+	if len(adIDs) == 0 {
+		return images, nil
+	}
+
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, ad_id, filename, position, is_cover
+		FROM ad_images
+		WHERE ad_id = ANY($1)
+		ORDER BY ad_id, position
+	`, adIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var img models.AdImage
+		var adID int
+		if err := rows.Scan(&img.ID, &adID, &img.Filename, &img.Position, &img.IsCover); err != nil {
+			return nil, err
+		}
+		images[adID] = append(images[adID], img)
+	}
+
+	return images, rows.Err()
+}
+
+// attachImages заполняет поле Images у списка объявлений
+func attachImages(ctx context.Context, q queryer, ads []*models.Ad) error {
+	ids := make([]int, len(ads))
+	for i, ad := range ads {
+		ids[i] = ad.ID
+	}
+
+	images, err := loadAdImages(ctx, q, ids)
+	if err != nil {
+		return err
+	}
+
+	for _, ad := range ads {
+		ad.Images = images[ad.ID]
+		if ad.Images == nil {
+			ad.Images = []models.AdImage{}
+		}
+	}
+	return nil
+}
+
+// lockAd блокирует строку объявления до конца транзакции, чтобы изменения
+// набора изображений не пересекались, и возвращает количество изображений
+func lockAd(ctx context.Context, tx *sql.Tx, adID int) (int, error) {
+	var id int
+	err := tx.QueryRowContext(ctx, "SELECT id FROM ads WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", adID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("ad with id %d does not exist", adID)
+		}
+		return 0, err
+	}
+
+	var count int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM ad_images WHERE ad_id = $1", adID).Scan(&count)
+	return count, err
+}
+
+// addImages добавляет изображения в конец списка; если обложки еще нет, ею становится первое.
+// Файлы проверяются в хранилище под блокировкой: если файл успели удалить
+// после загрузки, возвращается ErrImageFileMissing.
+func addImages(ctx context.Context, tx *sql.Tx, store storage.Storage, adID int, filenames []string) error {
+	count, err := lockAd(ctx, tx, adID)
+	if err != nil {
+		return err
+	}
+
+	if count+len(filenames) > models.MaxAdImages {
+		return fmt.Errorf("ad cannot have more than %d images", models.MaxAdImages)
+	}
+
+	if err := lockImageFiles(ctx, tx, filenames); err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		if _, err := store.Stat(ctx, filename); err != nil {
+			if errors.Is(err, storage.ErrNotFound) {
+				return ErrImageFileMissing
+			}
+			return err
+		}
+	}
+
+	for i, filename := range filenames {
+		isCover := count == 0 && i == 0
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO ad_images (ad_id, filename, position, is_cover)
+			VALUES ($1, $2, $3, $4)
+		`, adID, filename, count+i, isCover)
+		if err != nil {
+			return err
+		}
+
+		if isCover {
+			if _, err := tx.ExecContext(ctx, "UPDATE ads SET image_filename = $1 WHERE id = $2", filename, adID); err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO image_files (filename, ref_count) VALUES ($1, 1)
+			ON CONFLICT (filename) DO UPDATE SET ref_count = image_files.ref_count + 1
+		`, filename)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// releaseImages уменьшает счетчики ссылок на файлы и возвращает файлы,
+// на которые больше никто не ссылается. Удалять их можно только после фиксации транзакции.
+func releaseImages(ctx context.Context, tx *sql.Tx, filenames []string) ([]string, error) {
+	if err := lockImageFiles(ctx, tx, filenames); err != nil {
+		return nil, err
+	}
+
+	var unused []string
+	for _, filename := range filenames {
+		var refCount int
+		err := tx.QueryRowContext(ctx, `
+			UPDATE image_files SET ref_count = ref_count - 1
+			WHERE filename = $1 AND ref_count > 1
+			RETURNING ref_count
+		`, filename).Scan(&refCount)
+		if err == nil {
+			continue
+		}
+		if err != sql.ErrNoRows {
+			return nil, err
+		}
+
+		// Это была последняя ссылка
+		if _, err := tx.ExecContext(ctx, "DELETE FROM image_files WHERE filename = $1", filename); err != nil {
+			return nil, err
+		}
+		unused = append(unused, filename)
+	}
+	return unused, nil
+}
+
+// GetImages возвращает изображения объявления в порядке отображения
+func (r *AdRepository) GetImages(ctx context.Context, adID int) ([]models.AdImage, error) {
+	images, err := loadAdImages(ctx, r.DB, []int{adID})
+	if err != nil {
+		return nil, err
+	}
+	if images[adID] == nil {
+		return []models.AdImage{}, nil
+	}
+	return images[adID], nil
+}
+
+// AddImages добавляет уже сохраненные файлы к объявлению
+func (r *AdRepository) AddImages(ctx context.Context, adID int, filenames []string) ([]models.AdImage, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if err := addImages(ctx, tx, r.store, adID, filenames); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.GetImages(ctx, adID)
+}
+
+// ReorderImages задает порядок изображений; imageIDs должен содержать все изображения объявления
+func (r *AdRepository) ReorderImages(ctx context.Context, adID int, imageIDs []int) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	count, err := lockAd(ctx, tx, adID)
+	if err != nil {
+		return err
+	}
+
+	if len(imageIDs) != count {
+		return fmt.Errorf("image_ids must list every image of the ad exactly once")
+	}
+
+	// Позиция изображения - его индекс в переданном списке
+	res, err := tx.ExecContext(ctx, `
+		UPDATE ad_images i
+		SET position = o.ord - 1
+		FROM unnest($1::int[]) WITH ORDINALITY AS o(id, ord)
+		WHERE i.id = o.id AND i.ad_id = $2
+	`, imageIDs, adID)
+	if err != nil {
+		return err
+	}
+
+	// Если в списке есть дубликаты или чужие изображения, обновится меньше строк
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if int(updated) != count {
+		return fmt.Errorf("image_ids must list every image of the ad exactly once")
+	}
+
+	return tx.Commit()
+}
+
+// SetCoverImage делает изображение обложкой объявления
+func (r *AdRepository) SetCoverImage(ctx context.Context, adID, imageID int) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := lockAd(ctx, tx, adID); err != nil {
+		return err
+	}
+
+	var filename string
+	err = tx.QueryRowContext(ctx, "SELECT filename FROM ad_images WHERE id = $1 AND ad_id = $2", imageID, adID).Scan(&filename)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("image with id %d does not exist", imageID)
+		}
+		return err
+	}
+
+	// Сначала снимаем текущую обложку, иначе сработает уникальный индекс
+	if _, err := tx.ExecContext(ctx, "UPDATE ad_images SET is_cover = FALSE WHERE ad_id = $1 AND is_cover", adID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE ad_images SET is_cover = TRUE WHERE id = $1", imageID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE ads SET image_filename = $1 WHERE id = $2", filename, adID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// DeleteImage удаляет изображение объявления. Если это была обложка,
+// обложкой становится первое из оставшихся. Файл удаляется после фиксации транзакции.
+func (r *AdRepository) DeleteImage(ctx context.Context, adID, imageID int) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	count, err := lockAd(ctx, tx, adID)
+	if err != nil {
+		return err
+	}
+
+	var filename string
+	var position int
+	var isCover bool
+	err = tx.QueryRowContext(ctx, `
+		DELETE FROM ad_images
+		WHERE id = $1 AND ad_id = $2
+		RETURNING filename, position, is_cover
+	`, imageID, adID).Scan(&filename, &position, &isCover)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("image with id %d does not exist", imageID)
+		}
+		return err
+	}
+
+	if count == 1 {
+		return fmt.Errorf("ad must have at least one image")
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE ad_images SET position = position - 1 WHERE ad_id = $1 AND position > $2", adID, position)
+	if err != nil {
+		return err
+	}
+
+	if isCover {
+		_, err = tx.ExecContext(ctx, `
+			WITH cover AS (
+				UPDATE ad_images SET is_cover = TRUE
+				WHERE ad_id = $1 AND position = 0
+				RETURNING filename
+			)
+			UPDATE ads SET image_filename = cover.filename FROM cover WHERE ads.id = $1
+		`, adID)
+		if err != nil {
+			return err
+		}
+	}
+
+	unused, err := releaseImages(ctx, tx, []string{filename})
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	r.removeImageFiles(ctx, unused...)
+	return nil
+}
+
+// DiscardImages удаляет сохраненные файлы, которые не удалось привязать
+// к объявлению. Файлы, на которые ссылаются другие объявления, остаются.
+func (r *AdRepository) DiscardImages(ctx context.Context, filenames []string) {
+	r.removeImageFiles(ctx, filenames...)
+}
+
+// removeImageFiles удаляет файлы изображений вместе с их вариантами, если на них
+// никто не ссылается. Вызывается только после успешной фиксации транзакции,
+// ошибки логируются.
+func (r *AdRepository) removeImageFiles(ctx context.Context, filenames ...string) {
+	// Транзакция уже зафиксирована, поэтому очистку не прерываем при отмене запроса
+	ctx = context.WithoutCancel(ctx)
+
+	for _, filename := range filenames {
+		if filename == "" {
+			continue
+		}
+		if err := r.removeImageFile(ctx, filename); err != nil {
+			slog.Error("failed to remove image file", "error", err, "filename", filename)
+		}
+	}
+}
+
+// removeImageFile удаляет файл без ссылок, удерживая его блокировку: пока файл
+// удаляется, другое объявление не может на него сослаться, а ссылка, созданная
+// до блокировки, видна в image_files и файл сохраняет.
+func (r *AdRepository) removeImageFile(ctx context.Context, filename string) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockImageFiles(ctx, tx, []string{filename}); err != nil {
+		return err
+	}
+
+	var referenced bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM image_files WHERE filename = $1)", filename).Scan(&referenced)
+	if err != nil {
+		return err
+	}
+	if referenced {
+		return nil
+	}
+
+	for _, name := range imaging.Filenames(filename) {
+		if err := r.store.Delete(ctx, name); err != nil {
+			slog.Error("failed to remove image file", "error", err, "filename", name)
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,18 @@
+package models
+
+type AdList struct {
+	Items      []Ad      `json:"items"`
+	Total      int       `json:"total"`
+	Page       int       `json:"page,omitempty"`
+	Limit      int       `json:"limit"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+	PrevCursor string    `json:"prev_cursor,omitempty"`
+	Links      PageLinks `json:"links"`
+	Facets     *AdFacets `json:"facets,omitempty"`
+}
+
+type PageLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
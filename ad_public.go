@@ -0,0 +1,118 @@
+package models
+
+import "time"
+
+// Публичные представления объявлений для анонимных посетителей. Они не
+// содержат контактных данных продавца и статуса объявления: в публичные
+// ответы попадают только включенные объявления.
+
+// PublicUser - продавец без email
+type PublicUser struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type PublicAd struct {
+	ID          int          `json:"id"`
+	User        PublicUser   `json:"user"`
+	Category    Category     `json:"category"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	Price       float64      `json:"price"`
+	Image       string       `json:"image"`
+	Images      []AdImage    `json:"images"`
+	Attributes  AdAttributes `json:"attributes"`
+	CreatedAt   time.Time    `json:"created_at"`
+}
+
+func NewPublicAd(ad *Ad) PublicAd {
+	return PublicAd{
+		ID:          ad.ID,
+		User:        PublicUser{ID: ad.User.ID, Name: ad.User.Name},
+		Category:    ad.Category,
+		Title:       ad.Title,
+		Description: ad.Description,
+		Price:       ad.Price,
+		Image:       ad.Image,
+		Images:      ad.Images,
+		Attributes:  ad.Attributes,
+		CreatedAt:   ad.CreatedAt,
+	}
+}
+
+// PublicAdFacets - фасеты без счетчика по статусу
+type PublicAdFacets struct {
+	Categories   []CategoryFacet    `json:"categories"`
+	PriceBuckets []PriceBucketFacet `json:"price_buckets"`
+}
+
+func newPublicAdFacets(f *AdFacets) *PublicAdFacets {
+	if f == nil {
+		return nil
+	}
+	return &PublicAdFacets{Categories: f.Categories, PriceBuckets: f.PriceBuckets}
+}
+
+type PublicAdList struct {
+	Items      []PublicAd      `json:"items"`
+	Total      int             `json:"total"`
+	Page       int             `json:"page,omitempty"`
+	Limit      int             `json:"limit"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	PrevCursor string          `json:"prev_cursor,omitempty"`
+	Links      PageLinks       `json:"links"`
+	Facets     *PublicAdFacets `json:"facets,omitempty"`
+}
+
+func NewPublicAdList(list *AdList) PublicAdList {
+	items := make([]PublicAd, len(list.Items))
+	for i := range list.Items {
+		items[i] = NewPublicAd(&list.Items[i])
+	}
+	return PublicAdList{
+		Items:      items,
+		Total:      list.Total,
+		Page:       list.Page,
+		Limit:      list.Limit,
+		NextCursor: list.NextCursor,
+		PrevCursor: list.PrevCursor,
+		Links:      list.Links,
+		Facets:     newPublicAdFacets(list.Facets),
+	}
+}
+
+type PublicAdSearchResult struct {
+	PublicAd
+	Rank      float64     `json:"rank"`
+	Highlight AdHighlight `json:"highlight"`
+}
+
+type PublicAdSearchList struct {
+	Query  string                 `json:"query"`
+	Items  []PublicAdSearchResult `json:"items"`
+	Total  int                    `json:"total"`
+	Page   int                    `json:"page"`
+	Limit  int                    `json:"limit"`
+	Links  PageLinks              `json:"links"`
+	Facets *PublicAdFacets        `json:"facets,omitempty"`
+}
+
+func NewPublicAdSearchList(list *AdSearchList) PublicAdSearchList {
+	items := make([]PublicAdSearchResult, len(list.Items))
+	for i := range list.Items {
+		items[i] = PublicAdSearchResult{
+			PublicAd:  NewPublicAd(&list.Items[i].Ad),
+			Rank:      list.Items[i].Rank,
+			Highlight: list.Items[i].Highlight,
+		}
+	}
+	return PublicAdSearchList{
+		Query:  list.Query,
+		Items:  items,
+		Total:  list.Total,
+		Page:   list.Page,
+		Limit:  list.Limit,
+		Links:  list.Links,
+		Facets: newPublicAdFacets(list.Facets),
+	}
+}
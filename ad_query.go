@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang-test/internal/models"
+)
+
+// Допустимые поля сортировки и соответствующие им колонки
+var adSortColumns = map[string]string{
+	"price":      "a.price",
+	"created_at": "a.created_at",
+	"title":      "a.title",
+}
+
+// Границы ценовых диапазонов для фасетов
+var priceBucketBounds = []float64{1000, 5000, 10000, 50000, 100000}
+
+// Конфигурации полнотекстового поиска для параметра lang.
+// По умолчанию запрос разбирается сразу в русской и английской конфигурациях.
+var searchConfigs = map[string][]string{
+	"":   {"russian", "english"},
+	"ru": {"russian"},
+	"en": {"english"},
+}
+
+// whereBuilder собирает условия WHERE с позиционными параметрами
+type whereBuilder struct {
+	conds []string
+	args  []interface{}
+}
+
+// param добавляет аргумент запроса и возвращает номер его плейсхолдера
+func (b *whereBuilder) param(arg interface{}) int {
+	b.args = append(b.args, arg)
+	return len(b.args)
+}
+
+// cond добавляет условие без параметров
+func (b *whereBuilder) cond(cond string) {
+	b.conds = append(b.conds, cond)
+}
+
+// add добавляет условие, в котором %d заменяется номером параметра
+func (b *whereBuilder) add(cond string, arg interface{}) {
+	b.conds = append(b.conds, fmt.Sprintf(cond, b.param(arg)))
+}
+
+func (b *whereBuilder) String() string {
+	if len(b.conds) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(b.conds, " AND ")
+}
+
+func adFilterWhere(filter models.AdFilter) *whereBuilder {
+	b := &whereBuilder{}
+
+	if filter.Deleted {
+		b.cond("a.deleted_at IS NOT NULL")
+	} else {
+		b.cond("a.deleted_at IS NULL")
+	}
+
+	// Категория включает объявления всех своих подкатегорий
+	if filter.CategoryID != nil {
+		b.add("a.category_id IN ("+categorySubtree+")", *filter.CategoryID)
+	}
+	if filter.UserID != nil {
+		b.add("a.user_id = $%d", *filter.UserID)
+	}
+	if filter.IsEnabled != nil {
+		b.add("a.is_enabled = $%d", *filter.IsEnabled)
+	}
+	if filter.PriceMin != nil {
+		b.add("a.price >= $%d", *filter.PriceMin)
+	}
+	if filter.PriceMax != nil {
+		b.add("a.price <= $%d", *filter.PriceMax)
+	}
+	if filter.CreatedAfter != nil {
+		b.add("a.created_at >= $%d", *filter.CreatedAfter)
+	}
+	if filter.CreatedBefore != nil {
+		b.add("a.created_at < $%d", *filter.CreatedBefore)
+	}
+	for _, f := range filter.Attributes {
+		b.addAttribute(f)
+	}
+
+	return b
+}
+
+// addAttribute добавляет условие на атрибут объявления. Имя атрибута
+// проверено по models.IsAttributeName, поэтому подставляется в текст запроса.
+func (b *whereBuilder) addAttribute(f models.AttributeFilter) {
+	switch f.Op {
+	case models.AttrOpEq, models.AttrOpNe:
+		// Отдельные условия @> через OR, а не ANY(массив): так их покрывает GIN-индекс
+		var contains []string
+		for _, doc := range attributeDocs(f) {
+			contains = append(contains, fmt.Sprintf("a.attributes @> $%d::jsonb", b.param(doc)))
+		}
+		if f.Op == models.AttrOpEq {
+			b.cond("(" + strings.Join(contains, " OR ") + ")")
+		} else {
+			b.cond(fmt.Sprintf("(a.attributes ? '%s' AND NOT (%s))", f.Name, strings.Join(contains, " OR ")))
+		}
+	default:
+		// Сравниваются только числовые значения, остальные не подходят
+		b.add(fmt.Sprintf(
+			"CASE WHEN jsonb_typeof(a.attributes->'%[1]s') = 'number' THEN (a.attributes->>'%[1]s')::numeric END %[2]s $%%d::numeric",
+			f.Name, f.Op,
+		), f.Values[0])
+	}
+}
+
+// attributeDocs возвращает JSON-документы {"имя": значение} для проверки
+// вхождения (@>). Тип значения из строки запроса неизвестен, поэтому кроме
+// строки проверяются его варианты как числа и логического значения.
+func attributeDocs(f models.AttributeFilter) []string {
+	var docs []string
+	for _, v := range f.Values {
+		variants := []interface{}{v}
+		if n, err := strconv.ParseFloat(v, 64); err == nil && !math.IsInf(n, 0) && !math.IsNaN(n) {
+			variants = append(variants, n)
+		}
+		if v == "true" || v == "false" {
+			variants = append(variants, v == "true")
+		}
+		for _, variant := range variants {
+			// Строки, числа и bool всегда сериализуются без ошибок
+			doc, _ := json.Marshal(map[string]interface{}{f.Name: variant})
+			docs = append(docs, string(doc))
+		}
+	}
+	return docs
+}
+
+// addCursor ограничивает выборку строками после (или до) позиции курсора
+func (b *whereBuilder) addCursor(cursor *models.AdCursor) {
+	op := "<"
+	if cursor.Backward {
+		op = ">"
+	}
+	b.conds = append(b.conds, fmt.Sprintf("(a.created_at, a.id) %s ($%d, $%d)", op, b.param(cursor.CreatedAt), b.param(cursor.ID)))
+}
+
+// adOrderBy возвращает выражение ORDER BY; id добавляется для стабильного порядка
+func adOrderBy(filter models.AdFilter) string {
+	if filter.Cursor != nil {
+		// При движении назад выбираем строки в обратном порядке и разворачиваем их после чтения
+		if filter.Cursor.Backward {
+			return "ORDER BY a.created_at ASC, a.id ASC"
+		}
+		return "ORDER BY a.created_at DESC, a.id DESC"
+	}
+
+	column, ok := adSortColumns[filter.SortBy]
+	if !ok {
+		column = adSortColumns["created_at"]
+	}
+	direction := "DESC"
+	if filter.SortOrder == "asc" {
+		direction = "ASC"
+	}
+	return fmt.Sprintf("ORDER BY %s %s, a.id %s", column, direction, direction)
+}
+
+// addSearch добавляет условие полнотекстового поиска и возвращает JOIN,
+// в котором вычисляется запрос q.query
+func (b *whereBuilder) addSearch(q, lang string) (string, error) {
+	configs, ok := searchConfigs[lang]
+	if !ok {
+		return "", fmt.Errorf("unsupported search language %q", lang)
+	}
+
+	tsQuery := buildTSQuery(q)
+	if tsQuery == "" {
+		return "", fmt.Errorf("search query is empty")
+	}
+
+	join := fmt.Sprintf("CROSS JOIN LATERAL (SELECT %s AS query) q", tsQueryExpr(configs, b.param(tsQuery)))
+	b.conds = append(b.conds, "a.search_vector @@ q.query")
+	return join, nil
+}
+
+// tsQueryExpr объединяет через OR разбор запроса в каждой из конфигураций
+func tsQueryExpr(configs []string, param int) string {
+	parts := make([]string, len(configs))
+	for i, cfg := range configs {
+		parts[i] = fmt.Sprintf("to_tsquery('%s', $%d)", cfg, param)
+	}
+	return "(" + strings.Join(parts, " || ") + ")"
+}
+
+// buildTSQuery переводит пользовательский запрос в синтаксис to_tsquery:
+// "слова в кавычках" ищутся как фраза, слово* - по префиксу, -слово исключается.
+// Все символы, кроме букв и цифр, отбрасываются, поэтому результат всегда корректен.
+func buildTSQuery(q string) string {
+	var terms []string
+	rest := q
+
+	for {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			break
+		}
+
+		negate := false
+		if rest[0] == '-' {
+			negate = true
+			rest = rest[1:]
+		}
+
+		var term string
+		if strings.HasPrefix(rest, `"`) {
+			var phrase string
+			if end := strings.IndexByte(rest[1:], '"'); end >= 0 {
+				phrase, rest = rest[1:end+1], rest[end+2:]
+			} else {
+				phrase, rest = rest[1:], ""
+			}
+			words := searchWords(phrase)
+			if len(words) == 0 {
+				continue
+			}
+			term = "(" + strings.Join(words, " <-> ") + ")"
+		} else {
+			var word string
+			if end := strings.IndexFunc(rest, unicode.IsSpace); end >= 0 {
+				word, rest = rest[:end], rest[end:]
+			} else {
+				word, rest = rest, ""
+			}
+			words := searchWords(word)
+			if len(words) == 0 {
+				continue
+			}
+			// Слова через дефис или точку ищутся как фраза
+			term = strings.Join(words, " <-> ")
+			if strings.HasSuffix(word, "*") {
+				term += ":*"
+			}
+			if len(words) > 1 {
+				term = "(" + term + ")"
+			}
+		}
+
+		if negate {
+			term = "!" + term
+		}
+		terms = append(terms, term)
+	}
+
+	return strings.Join(terms, " & ")
+}
+
+func searchWords(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
@@ -1,263 +1,675 @@
-package repository
-
-import (
-	"context"
-	"database/sql"
-	"fmt"
-	"golang-test/internal/models"
-	"os"
-	"path/filepath"
-)
-
-type AdRepository struct {
-	DB *sql.DB
-}
-
-func NewAdRepository(db *sql.DB) *AdRepository {
-	return &AdRepository{DB: db}
-}
-
-func (r *AdRepository) GetByID(ctx context.Context, id int) (*models.Ad, error) {
-	query := `
-		SELECT 
-			a.id, a.title, a.description, a.price, a.image_filename, 
-			a.is_enabled, a.created_at,
-			u.id, u.name, u.email, u.created_at,
-			c.id, c.name, c.extra_property
-		FROM ads a
-		JOIN users u ON a.user_id = u.id
-		JOIN categories c ON a.category_id = c.id
-		WHERE a.id = $1
-	`
-
-	var ad models.Ad
-	var user models.User
-	var category models.Category
-
-	err := r.DB.QueryRowContext(ctx, query, id).Scan(
-		&ad.ID, &ad.Title, &ad.Description, &ad.Price, &ad.Image,
-		&ad.IsEnabled, &ad.CreatedAt,
-		&user.ID, &user.Name, &user.Email, &user.CreatedAt,
-		&category.ID, &category.Name, &category.ExtraProperty,
-	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, err
-	}
-
-	ad.User = user
-	ad.Category = category
-
-	return &ad, nil
-}
-
-func (r *AdRepository) GetAll(ctx context.Context) ([]models.Ad, error) {
-	query := `
-		SELECT 
-			a.id, a.title, a.description, a.price, a.image_filename, 
-			a.is_enabled, a.created_at,
-			u.id, u.name, u.email, u.created_at,
-			c.id, c.name, c.extra_property
-		FROM ads a
-		JOIN users u ON a.user_id = u.id
-		JOIN categories c ON a.category_id = c.id
-		ORDER BY a.created_at DESC
-	`
-
-	rows, err := r.DB.QueryContext(ctx, query)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var ads []models.Ad
-
-	for rows.Next() {
-		var ad models.Ad
-		var user models.User
-		var category models.Category
-
-		err := rows.Scan(
-			&ad.ID, &ad.Title, &ad.Description, &ad.Price, &ad.Image,
-			&ad.IsEnabled, &ad.CreatedAt,
-			&user.ID, &user.Name, &user.Email, &user.CreatedAt,
-			&category.ID, &category.Name, &category.ExtraProperty,
-		)
-
-		if err != nil {
-			return nil, err
-		}
-
-		ad.User = user
-		ad.Category = category
-		ads = append(ads, ad)
-	}
-
-	return ads, nil
-}
-
-func (r *AdRepository) Create(ctx context.Context, ad *models.AdCreate, imageFilename string) (*models.Ad, error) {
-	tx, err := r.DB.BeginTx(ctx, nil)
-	if err != nil {
-		return nil, err
-	}
-	defer tx.Rollback()
-
-	// Проверяем существование пользователя
-	var userExists bool
-	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", ad.UserID).Scan(&userExists)
-	if err != nil {
-		return nil, err
-	}
-	if !userExists {
-		return nil, fmt.Errorf("user with id %d does not exist", ad.UserID)
-	}
-
-	// Проверяем существование категории
-	var categoryExists bool
-	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM categories WHERE id = $1)", ad.CategoryID).Scan(&categoryExists)
-	if err != nil {
-		return nil, err
-	}
-	if !categoryExists {
-		return nil, fmt.Errorf("category with id %d does not exist", ad.CategoryID)
-	}
-
-	query := `
-		INSERT INTO ads (user_id, category_id, title, description, price, image_filename, is_enabled)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
-		RETURNING id, created_at
-	`
-
-	var createdAd models.Ad
-	err = tx.QueryRowContext(ctx, query,
-		ad.UserID, ad.CategoryID, ad.Title, ad.Description, ad.Price,
-		imageFilename, true, // По умолчанию включено
-	).Scan(&createdAd.ID, &createdAd.CreatedAt)
-
-	if err != nil {
-		return nil, err
-	}
-
-	// Получаем полные данные объявления
-	fullQuery := `
-		SELECT 
-			a.title, a.description, a.price, a.image_filename, a.is_enabled,
-			u.id, u.name, u.email, u.created_at,
-			c.id, c.name, c.extra_property
-		FROM ads a
-		JOIN users u ON a.user_id = u.id
-		JOIN categories c ON a.category_id = c.id
-		WHERE a.id = $1
-	`
-
-	var user models.User
-	var category models.Category
-
-	err = tx.QueryRowContext(ctx, fullQuery, createdAd.ID).Scan(
-		&createdAd.Title, &createdAd.Description, &createdAd.Price, &createdAd.Image,
-		&createdAd.IsEnabled,
-		&user.ID, &user.Name, &user.Email, &user.CreatedAt,
-		&category.ID, &category.Name, &category.ExtraProperty,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	createdAd.User = user
-	createdAd.Category = category
-
-	if err = tx.Commit(); err != nil {
-		return nil, err
-	}
-
-	return &createdAd, nil
-}
-
-func (r *AdRepository) Update(ctx context.Context, id int, update *models.AdUpdate, imageFilename string) error {
-	// Сначала проверим существование объявления
-	var exists bool
-	err := r.DB.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM ads WHERE id = $1)", id).Scan(&exists)
-	if err != nil {
-		return err
-	}
-	if !exists {
-		return fmt.Errorf("ad with id %d does not exist", id)
-	}
-
-	query := `
-		UPDATE ads 
-		SET title = $1, description = $2, price = $3
-		WHERE id = $4
-	`
-
-	args := []interface{}{update.Title, update.Description, update.Price, id}
-
-	// Если передано новое изображение, обновляем его
-	if imageFilename != "" {
-		// Получаем старое имя файла для удаления
-		var oldImage string
-		err := r.DB.QueryRowContext(ctx, "SELECT image_filename FROM ads WHERE id = $1", id).Scan(&oldImage)
-		if err != nil {
-			return err
-		}
-
-		// Удаляем старый файл
-		if oldImage != "" {
-			oldPath := filepath.Join("uploads", "images", oldImage)
-			os.Remove(oldPath)
-		}
-
-		query = `
-			UPDATE ads 
-			SET title = $1, description = $2, price = $3, image_filename = $4
-			WHERE id = $5
-		`
-		args = []interface{}{update.Title, update.Description, update.Price, imageFilename, id}
-	}
-
-	_, err = r.DB.ExecContext(ctx, query, args...)
-	return err
-}
-
-func (r *AdRepository) Toggle(ctx context.Context, id int, enabled bool) error {
-	query := "UPDATE ads SET is_enabled = $1 WHERE id = $2"
-	_, err := r.DB.ExecContext(ctx, query, enabled, id)
-	return err
-}
-
-func (r *AdRepository) Delete(ctx context.Context, id int) error {
-	tx, err := r.DB.BeginTx(ctx, nil)
-	if err != nil {
-		return err
-	}
-	defer tx.Rollback()
-
-	// Получаем имя файла изображения
-	var imageFilename string
-	err = tx.QueryRowContext(ctx, "SELECT image_filename FROM ads WHERE id = $1", id).Scan(&imageFilename)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("ad with id %d does not exist", id)
-		}
-		return err
-	}
-
-	// Удаляем объявление
-	_, err = tx.ExecContext(ctx, "DELETE FROM ads WHERE id = $1", id)
-	if err != nil {
-		return err
-	}
-
-	// Удаляем файл изображения
-	if imageFilename != "" {
-		imagePath := filepath.Join("uploads", "images", imageFilename)
-		os.Remove(imagePath)
-	}
-
-	return tx.Commit()
-}
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"golang-test/internal/models"
+	"golang-test/internal/storage"
+	"slices"
+	"time"
+)
+
+type AdRepository struct {
+	DB    *sql.DB
+	store storage.Storage
+}
+
+func NewAdRepository(db *sql.DB, store storage.Storage) *AdRepository {
+	return &AdRepository{DB: db, store: store}
+}
+
+func (r *AdRepository) GetByID(ctx context.Context, id int) (*models.Ad, error) {
+	query := `
+		SELECT 
+			a.id, a.title, a.description, a.price, a.image_filename, 
+			a.is_enabled, a.created_at, a.attributes,
+			u.id, u.name, u.email, u.created_at,
+			c.id, c.name, c.parent_id
+		FROM ads a
+		JOIN users u ON a.user_id = u.id
+		JOIN categories c ON a.category_id = c.id
+		WHERE a.id = $1 AND a.deleted_at IS NULL
+	`
+
+	var ad models.Ad
+	var user models.User
+	var category models.Category
+
+	err := r.DB.QueryRowContext(ctx, query, id).Scan(
+		&ad.ID, &ad.Title, &ad.Description, &ad.Price, &ad.Image,
+		&ad.IsEnabled, &ad.CreatedAt, &ad.Attributes,
+		&user.ID, &user.Name, &user.Email, &user.CreatedAt,
+		&category.ID, &category.Name, &category.ParentID,
+	)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	ad.User = user
+	ad.Category = category
+
+	if err := attachImages(ctx, r.DB, []*models.Ad{&ad}); err != nil {
+		return nil, err
+	}
+	if ad.Category.Breadcrumbs, err = categoryBreadcrumbs(ctx, r.DB, category.ID); err != nil {
+		return nil, err
+	}
+
+	return &ad, nil
+}
+
+// AdPage - результат постраничной выборки объявлений
+type AdPage struct {
+	Ads   []models.Ad
+	Total int
+	// HasMore показывает, есть ли строки дальше в направлении выборки
+	HasMore bool
+}
+
+// GetAll возвращает страницу объявлений по фильтру и общее количество подходящих записей
+func (r *AdRepository) GetAll(ctx context.Context, filter models.AdFilter) (*AdPage, error) {
+	where := adFilterWhere(filter)
+
+	var total int
+	countQuery := "SELECT COUNT(*) FROM ads a " + where.String()
+	if err := r.DB.QueryRowContext(ctx, countQuery, where.args...).Scan(&total); err != nil {
+		return nil, err
+	}
+
+	// Для keyset-пагинации читаем на одну строку больше, чтобы понять, есть ли продолжение
+	limit, offset := filter.Limit, filter.Offset()
+	if filter.Cursor != nil {
+		where.addCursor(filter.Cursor)
+		limit, offset = filter.Limit+1, 0
+	}
+
+	query := fmt.Sprintf(`
+		SELECT 
+			a.id, a.title, a.description, a.price, a.image_filename, 
+			a.is_enabled, a.created_at, a.deleted_at, a.attributes,
+			u.id, u.name, u.email, u.created_at,
+			c.id, c.name, c.parent_id
+		FROM ads a
+		JOIN users u ON a.user_id = u.id
+		JOIN categories c ON a.category_id = c.id
+		%s
+		%s
+		LIMIT $%d OFFSET $%d
+	`, where.String(), adOrderBy(filter), len(where.args)+1, len(where.args)+2)
+
+	args := append(where.args, limit, offset)
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ads := make([]models.Ad, 0, limit)
+
+	for rows.Next() {
+		var ad models.Ad
+		var user models.User
+		var category models.Category
+
+		err := rows.Scan(
+			&ad.ID, &ad.Title, &ad.Description, &ad.Price, &ad.Image,
+			&ad.IsEnabled, &ad.CreatedAt, &ad.DeletedAt, &ad.Attributes,
+			&user.ID, &user.Name, &user.Email, &user.CreatedAt,
+			&category.ID, &category.Name, &category.ParentID,
+		)
+
+		if err != nil {
+			return nil, err
+		}
+
+		ad.User = user
+		ad.Category = category
+		ads = append(ads, ad)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	adPtrs := make([]*models.Ad, len(ads))
+	for i := range ads {
+		adPtrs[i] = &ads[i]
+	}
+	if err := attachImages(ctx, r.DB, adPtrs); err != nil {
+		return nil, err
+	}
+
+	page := &AdPage{Ads: ads, Total: total}
+
+	if filter.Cursor == nil {
+		page.HasMore = offset+len(ads) < total
+		return page, nil
+	}
+
+	if len(ads) > filter.Limit {
+		page.HasMore = true
+		page.Ads = ads[:filter.Limit]
+	}
+	if filter.Cursor.Backward {
+		slices.Reverse(page.Ads)
+	}
+
+	return page, nil
+}
+
+// Search выполняет полнотекстовый поиск с учетом фильтров списка.
+// Если filter.SortBy равен "relevance", результаты упорядочиваются по рангу.
+func (r *AdRepository) Search(ctx context.Context, q, lang string, filter models.AdFilter) ([]models.AdSearchResult, int, error) {
+	where := adFilterWhere(filter)
+	queryJoin, err := where.addSearch(q, lang)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	countQuery := fmt.Sprintf("SELECT COUNT(*) FROM ads a %s %s", queryJoin, where.String())
+	if err := r.DB.QueryRowContext(ctx, countQuery, where.args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	innerOrder := "ORDER BY rank DESC, a.created_at DESC, a.id DESC"
+	outerOrder := "ORDER BY s.rank DESC, a.created_at DESC, a.id DESC"
+	if filter.SortBy != "relevance" {
+		innerOrder = adOrderBy(filter)
+		outerOrder = innerOrder
+	}
+
+	// Сниппеты строятся только для строк текущей страницы: ts_headline заметно дороже ранжирования
+	query := fmt.Sprintf(`
+		SELECT 
+			a.id, a.title, a.description, a.price, a.image_filename, 
+			a.is_enabled, a.created_at, a.attributes,
+			u.id, u.name, u.email, u.created_at,
+			c.id, c.name, c.parent_id,
+			s.rank,
+			ts_headline('%[1]s', a.title, s.query, 'HighlightAll=true, StartSel=<mark>, StopSel=</mark>'),
+			ts_headline('%[1]s', a.description, s.query, 'MaxFragments=2, MaxWords=30, MinWords=10, StartSel=<mark>, StopSel=</mark>')
+		FROM (
+			SELECT a.id, ts_rank_cd(a.search_vector, q.query) AS rank, q.query
+			FROM ads a
+			%[2]s
+			%[3]s
+			%[4]s
+			LIMIT $%[6]d OFFSET $%[7]d
+		) s
+		JOIN ads a ON a.id = s.id
+		JOIN users u ON a.user_id = u.id
+		JOIN categories c ON a.category_id = c.id
+		%[5]s
+	`, searchConfigs[lang][0], queryJoin, where.String(), innerOrder, outerOrder, len(where.args)+1, len(where.args)+2)
+
+	args := append(where.args, filter.Limit, filter.Offset())
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	results := make([]models.AdSearchResult, 0, filter.Limit)
+
+	for rows.Next() {
+		var res models.AdSearchResult
+		var user models.User
+		var category models.Category
+
+		err := rows.Scan(
+			&res.ID, &res.Title, &res.Description, &res.Price, &res.Image,
+			&res.IsEnabled, &res.CreatedAt, &res.Attributes,
+			&user.ID, &user.Name, &user.Email, &user.CreatedAt,
+			&category.ID, &category.Name, &category.ParentID,
+			&res.Rank, &res.Highlight.Title, &res.Highlight.Description,
+		)
+
+		if err != nil {
+			return nil, 0, err
+		}
+
+		res.User = user
+		res.Category = category
+		results = append(results, res)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	adPtrs := make([]*models.Ad, len(results))
+	for i := range results {
+		adPtrs[i] = &results[i].Ad
+	}
+	if err := attachImages(ctx, r.DB, adPtrs); err != nil {
+		return nil, 0, err
+	}
+
+	return results, total, nil
+}
+
+// Facets считает фасеты каталога для фильтра и, если q не пуст, для поискового запроса.
+// Фильтр по самому фасету не применяется: например, счетчики категорий
+// учитывают цену и статус, но не выбранную категорию.
+func (r *AdRepository) Facets(ctx context.Context, filter models.AdFilter, q, lang string) (*models.AdFacets, error) {
+	facets := &models.AdFacets{}
+
+	// where строит условия для фильтра с учетом поискового запроса
+	where := func(f models.AdFilter) (*whereBuilder, string, error) {
+		b := adFilterWhere(f)
+		if q == "" {
+			return b, "", nil
+		}
+		join, err := b.addSearch(q, lang)
+		return b, join, err
+	}
+
+	// Категории
+	categoryFilter := filter
+	categoryFilter.CategoryID = nil
+	b, join, err := where(categoryFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.DB.QueryContext(ctx, fmt.Sprintf(`
+		SELECT c.id, c.name, COUNT(*)
+		FROM ads a
+		JOIN categories c ON a.category_id = c.id
+		%s
+		%s
+		GROUP BY c.id, c.name
+		ORDER BY COUNT(*) DESC, c.name
+	`, join, b.String()), b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	facets.Categories = []models.CategoryFacet{}
+	for rows.Next() {
+		var cf models.CategoryFacet
+		if err := rows.Scan(&cf.ID, &cf.Name, &cf.Count); err != nil {
+			return nil, err
+		}
+		facets.Categories = append(facets.Categories, cf)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Ценовые диапазоны: width_bucket возвращает 0 для цен ниже первой границы
+	// и len(priceBucketBounds) для цен не ниже последней
+	priceFilter := filter
+	priceFilter.PriceMin, priceFilter.PriceMax = nil, nil
+	b, join, err = where(priceFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	bounds := b.param(priceBucketBounds)
+	priceRows, err := r.DB.QueryContext(ctx, fmt.Sprintf(`
+		SELECT width_bucket(a.price, $%d::numeric[]), COUNT(*)
+		FROM ads a
+		%s
+		%s
+		GROUP BY 1
+	`, bounds, join, b.String()), b.args...)
+	if err != nil {
+		return nil, err
+	}
+	defer priceRows.Close()
+
+	counts := make(map[int]int)
+	for priceRows.Next() {
+		var bucket, count int
+		if err := priceRows.Scan(&bucket, &count); err != nil {
+			return nil, err
+		}
+		counts[bucket] = count
+	}
+	if err := priceRows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := 0; i <= len(priceBucketBounds); i++ {
+		bucket := models.PriceBucketFacet{Count: counts[i]}
+		if i > 0 {
+			bucket.Min = priceBucketBounds[i-1]
+		}
+		if i < len(priceBucketBounds) {
+			max := priceBucketBounds[i]
+			bucket.Max = &max
+		}
+		facets.PriceBuckets = append(facets.PriceBuckets, bucket)
+	}
+
+	// Статус
+	statusFilter := filter
+	statusFilter.IsEnabled = nil
+	b, join, err = where(statusFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	err = r.DB.QueryRowContext(ctx, fmt.Sprintf(`
+		SELECT
+			COUNT(*) FILTER (WHERE a.is_enabled),
+			COUNT(*) FILTER (WHERE NOT a.is_enabled)
+		FROM ads a
+		%s
+		%s
+	`, join, b.String()), b.args...).Scan(&facets.Status.Enabled, &facets.Status.Disabled)
+	if err != nil {
+		return nil, err
+	}
+
+	return facets, nil
+}
+
+// Create создает объявление; первое изображение из ad.Images становится обложкой
+func (r *AdRepository) Create(ctx context.Context, ad *models.AdCreate) (*models.Ad, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Проверяем существование пользователя
+	var userExists bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)", ad.UserID).Scan(&userExists)
+	if err != nil {
+		return nil, err
+	}
+	if !userExists {
+		return nil, fmt.Errorf("user with id %d does not exist", ad.UserID)
+	}
+
+	// Проверяем существование категории и атрибуты по ее схеме
+	schema, err := categoryAttributes(ctx, tx, ad.CategoryID)
+	if err != nil {
+		return nil, err
+	}
+	attributes, err := schema.Validate(ad.Attributes)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO ads (user_id, category_id, title, description, price, image_filename, is_enabled, attributes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`
+
+	var createdAd models.Ad
+	err = tx.QueryRowContext(ctx, query,
+		ad.UserID, ad.CategoryID, ad.Title, ad.Description, ad.Price,
+		"", true, // Обложка проставляется при добавлении изображений; по умолчанию включено
+		attributes,
+	).Scan(&createdAd.ID, &createdAd.CreatedAt)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err = addImages(ctx, tx, r.store, createdAd.ID, ad.Images); err != nil {
+		return nil, err
+	}
+
+	// Получаем полные данные объявления
+	fullQuery := `
+		SELECT 
+			a.title, a.description, a.price, a.image_filename, a.is_enabled, a.attributes,
+			u.id, u.name, u.email, u.created_at,
+			c.id, c.name, c.parent_id
+		FROM ads a
+		JOIN users u ON a.user_id = u.id
+		JOIN categories c ON a.category_id = c.id
+		WHERE a.id = $1
+	`
+
+	var user models.User
+	var category models.Category
+
+	err = tx.QueryRowContext(ctx, fullQuery, createdAd.ID).Scan(
+		&createdAd.Title, &createdAd.Description, &createdAd.Price, &createdAd.Image,
+		&createdAd.IsEnabled, &createdAd.Attributes,
+		&user.ID, &user.Name, &user.Email, &user.CreatedAt,
+		&category.ID, &category.Name, &category.ParentID,
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	createdAd.User = user
+	createdAd.Category = category
+
+	if err = attachImages(ctx, tx, []*models.Ad{&createdAd}); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &createdAd, nil
+}
+
+// Update обновляет поля объявления и добавляет новые изображения из update.Images
+func (r *AdRepository) Update(ctx context.Context, id int, update *models.AdUpdate) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Атрибуты проверяются по схеме текущей категории объявления;
+	// без update.Attributes они остаются прежними
+	var attributes interface{}
+	if update.Attributes != nil {
+		var categoryID int
+		err := tx.QueryRowContext(ctx, "SELECT category_id FROM ads WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).Scan(&categoryID)
+		if err != nil {
+			if err == sql.ErrNoRows {
+				return fmt.Errorf("ad with id %d does not exist", id)
+			}
+			return err
+		}
+		schema, err := categoryAttributes(ctx, tx, categoryID)
+		if err != nil {
+			return err
+		}
+		if attributes, err = schema.Validate(update.Attributes); err != nil {
+			return err
+		}
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE ads 
+		SET title = $1, description = $2, price = $3, attributes = COALESCE($5::jsonb, attributes)
+		WHERE id = $4 AND deleted_at IS NULL
+	`, update.Title, update.Description, update.Price, id, attributes)
+	if err != nil {
+		return err
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return fmt.Errorf("ad with id %d does not exist", id)
+	}
+
+	if len(update.Images) > 0 {
+		if err := addImages(ctx, tx, r.store, id, update.Images); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetOwnerID возвращает ID владельца объявления
+func (r *AdRepository) GetOwnerID(ctx context.Context, id int) (int, error) {
+	var userID int
+	err := r.DB.QueryRowContext(ctx, "SELECT user_id FROM ads WHERE id = $1 AND deleted_at IS NULL", id).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("ad with id %d does not exist", id)
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (r *AdRepository) Toggle(ctx context.Context, id int, enabled bool) error {
+	query := "UPDATE ads SET is_enabled = $1 WHERE id = $2 AND deleted_at IS NULL"
+	_, err := r.DB.ExecContext(ctx, query, enabled, id)
+	return err
+}
+
+// Delete помечает объявление удаленным. Изображения и файлы сохраняются
+// до окончательной очистки, чтобы объявление можно было восстановить.
+func (r *AdRepository) Delete(ctx context.Context, id int) error {
+	res, err := r.DB.ExecContext(ctx, "UPDATE ads SET deleted_at = NOW() WHERE id = $1 AND deleted_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if deleted == 0 {
+		return fmt.Errorf("ad with id %d does not exist", id)
+	}
+	return nil
+}
+
+// Restore снимает пометку удаления. Объявление удаленного пользователя
+// восстанавливается только вместе с ним.
+func (r *AdRepository) Restore(ctx context.Context, id int) error {
+	var adDeleted, ownerDeleted bool
+	err := r.DB.QueryRowContext(ctx, `
+		SELECT a.deleted_at IS NOT NULL, u.deleted_at IS NOT NULL
+		FROM ads a
+		JOIN users u ON u.id = a.user_id
+		WHERE a.id = $1
+	`, id).Scan(&adDeleted, &ownerDeleted)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("ad with id %d does not exist", id)
+		}
+		return err
+	}
+	if !adDeleted {
+		return fmt.Errorf("ad with id %d is not deleted", id)
+	}
+	if ownerDeleted {
+		return fmt.Errorf("owner of ad with id %d is deleted", id)
+	}
+
+	_, err = r.DB.ExecContext(ctx, "UPDATE ads SET deleted_at = NULL WHERE id = $1", id)
+	return err
+}
+
+// Purge окончательно удаляет объявления, помеченные удаленными раньше before,
+// и возвращает их количество
+func (r *AdRepository) Purge(ctx context.Context, before time.Time) (int, error) {
+	rows, err := r.DB.QueryContext(ctx, "SELECT id FROM ads WHERE deleted_at < $1 ORDER BY id", before)
+	if err != nil {
+		return 0, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, id := range ids {
+		ok, err := r.purge(ctx, id, before)
+		if err != nil {
+			return purged, fmt.Errorf("failed to purge ad %d: %w", id, err)
+		}
+		if ok {
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+// purge удаляет объявление вместе с изображениями; файлы без других ссылок
+// удаляются после фиксации транзакции. Если объявление успели восстановить,
+// оно не трогается.
+func (r *AdRepository) purge(ctx context.Context, id int, before time.Time) (bool, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var locked int
+	err = tx.QueryRowContext(ctx, "SELECT id FROM ads WHERE id = $1 AND deleted_at < $2 FOR UPDATE", id, before).Scan(&locked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, err
+	}
+
+	// Получаем имена файлов изображений
+	rows, err := tx.QueryContext(ctx, "SELECT filename FROM ad_images WHERE ad_id = $1", id)
+	if err != nil {
+		return false, err
+	}
+	var filenames []string
+	for rows.Next() {
+		var filename string
+		if err := rows.Scan(&filename); err != nil {
+			rows.Close()
+			return false, err
+		}
+		filenames = append(filenames, filename)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+
+	// Удаляем объявление (изображения удалятся каскадно)
+	_, err = tx.ExecContext(ctx, "DELETE FROM ads WHERE id = $1", id)
+	if err != nil {
+		return false, err
+	}
+
+	// Файлы удаляются, только если на них не ссылаются другие объявления
+	unused, err := releaseImages(ctx, tx, filenames)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, err
+	}
+
+	r.removeImageFiles(ctx, unused...)
+	return true, nil
+}
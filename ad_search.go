@@ -0,0 +1,23 @@
+package models
+
+type AdHighlight struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+}
+
+// AdSearchResult - объявление, найденное полнотекстовым поиском
+type AdSearchResult struct {
+	Ad
+	Rank      float64     `json:"rank"`
+	Highlight AdHighlight `json:"highlight"`
+}
+
+type AdSearchList struct {
+	Query  string           `json:"query"`
+	Items  []AdSearchResult `json:"items"`
+	Total  int              `json:"total"`
+	Page   int              `json:"page"`
+	Limit  int              `json:"limit"`
+	Links  PageLinks        `json:"links"`
+	Facets *AdFacets        `json:"facets,omitempty"`
+}
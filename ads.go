@@ -1,427 +1,772 @@
-package handlers
-
-import (
-	"database/sql"
-	"fmt"
-	"log/slog"
-	"net/http"
-	"os"
-	"path/filepath"
-	"strconv"
-
-	"golang-test/internal/models"
-	"golang-test/internal/repository"
-
-	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
-)
-
-type AdHandler struct {
-	repo *repository.AdRepository
-}
-
-func NewAdHandler(repo *repository.AdRepository) *AdHandler {
-	return &AdHandler{repo: repo}
-}
-
-// GetAdByID получает объявление по ID
-// @Summary Получить объявление по ID
-// @Description Возвращает информацию об объявлении по его идентификатору
-// @Tags ads
-// @Accept json
-// @Produce json
-// @Param id path int true "ID объявления"
-// @Security APIKey
-// @Success 200 {object} models.Ad
-// @Failure 400 {object} ErrorResponse
-// @Failure 404 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
-// @Router /ads/{id} [get]
-func (h *AdHandler) GetAdByID(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid ad id",
-		})
-		return
-	}
-
-	ad, err := h.repo.GetByID(c.Request.Context(), id)
-	if err != nil {
-		slog.Error("failed to get ad", "error", err, "id", id)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "internal server error",
-		})
-		return
-	}
-
-	if ad == nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "ad not found",
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, ad)
-}
-
-// GetAllAds получает все объявления
-// @Summary Получить все объявления
-// @Description Возвращает список всех объявлений
-// @Tags ads
-// @Accept json
-// @Produce json
-// @Security APIKey
-// @Success 200 {array} models.Ad
-// @Failure 500 {object} ErrorResponse
-// @Router /ads [get]
-func (h *AdHandler) GetAllAds(c *gin.Context) {
-	ads, err := h.repo.GetAll(c.Request.Context())
-	if err != nil {
-		slog.Error("failed to get ads", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "internal server error",
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, ads)
-}
-
-// CreateAd создает новое объявление
-// @Summary Создать новое объявление
-// @Description Создает новое объявление с изображением
-// @Tags ads
-// @Accept multipart/form-data
-// @Produce json
-// @Param image formData file true "Изображение объявления (png, jpg, jpeg)"
-// @Param user_id formData int true "ID пользователя"
-// @Param category_id formData int true "ID категории"
-// @Param title formData string true "Заголовок объявления"
-// @Param description formData string true "Описание объявления"
-// @Param price formData number true "Цена"
-// @Security APIKey
-// @Success 201 {object} models.Ad
-// @Failure 400 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
-// @Router /ads [post]
-func (h *AdHandler) CreateAd(c *gin.Context) {
-	// 1. Получаем файл из запроса
-	file, err := c.FormFile("image")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "image is required",
-		})
-		return
-	}
-
-	// 2. Проверяем расширение
-	ext := filepath.Ext(file.Filename)
-	if ext != ".png" && ext != ".jpg" && ext != ".jpeg" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "only png/jpg/jpeg allowed",
-		})
-		return
-	}
-
-	// 3. Генерируем уникальное имя
-	filename := uuid.New().String() + ext
-
-	// 4. Путь сохранения
-	savePath := "uploads/images/" + filename
-
-	// 5. Сохраняем файл физически
-	if err := c.SaveUploadedFile(file, savePath); err != nil {
-		slog.Error("failed to save image", "error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "cannot save image",
-		})
-		return
-	}
-
-	// 6. Парсим остальные данные из формы
-	userID, err := strconv.Atoi(c.PostForm("user_id"))
-	if err != nil {
-		os.Remove(savePath)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid user_id",
-		})
-		return
-	}
-
-	categoryID, err := strconv.Atoi(c.PostForm("category_id"))
-	if err != nil {
-		os.Remove(savePath)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid category_id",
-		})
-		return
-	}
-
-	title := c.PostForm("title")
-	if title == "" {
-		os.Remove(savePath)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "title is required",
-		})
-		return
-	}
-
-	description := c.PostForm("description")
-	if description == "" {
-		os.Remove(savePath)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "description is required",
-		})
-		return
-	}
-
-	price, err := strconv.ParseFloat(c.PostForm("price"), 64)
-	if err != nil || price < 0 {
-		os.Remove(savePath)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid price",
-		})
-		return
-	}
-
-	// 7. Создаем объект для создания объявления
-	adCreate := models.AdCreate{
-		UserID:      userID,
-		CategoryID:  categoryID,
-		Title:       title,
-		Description: description,
-		Price:       price,
-		Image:       filename,
-	}
-
-	// 8. Создаем объявление в БД
-	ad, err := h.repo.Create(c.Request.Context(), &adCreate, filename)
-	if err != nil {
-		// Удаляем сохраненный файл, если не удалось создать запись в БД
-		os.Remove(savePath)
-		slog.Error("failed to create ad", "error", err)
-		errorMsg := "failed to create ad"
-		if err.Error() == fmt.Sprintf("user with id %d does not exist", userID) {
-			errorMsg = "user does not exist"
-		} else if err.Error() == fmt.Sprintf("category with id %d does not exist", categoryID) {
-			errorMsg = "category does not exist"
-		}
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": errorMsg,
-		})
-		return
-	}
-
-	c.JSON(http.StatusCreated, ad)
-}
-
-// UpdateAd обновляет объявление
-// @Summary Обновить объявление
-// @Description Обновляет информацию об объявлении
-// @Tags ads
-// @Accept multipart/form-data
-// @Produce json
-// @Param id path int true "ID объявления"
-// @Param image formData file false "Новое изображение (png, jpg, jpeg)"
-// @Param title formData string true "Заголовок объявления"
-// @Param description formData string true "Описание объявления"
-// @Param price formData number true "Цена"
-// @Security APIKey
-// @Success 200 {object} SuccessResponse
-// @Failure 400 {object} ErrorResponse
-// @Failure 404 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
-// @Router /ads/{id} [put]
-func (h *AdHandler) UpdateAd(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid ad id",
-		})
-		return
-	}
-
-	// Парсим данные из формы
-	title := c.PostForm("title")
-	description := c.PostForm("description")
-	priceStr := c.PostForm("price")
-
-	if title == "" || description == "" || priceStr == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "title, description and price are required",
-		})
-		return
-	}
-
-	price, err := strconv.ParseFloat(priceStr, 64)
-	if err != nil || price < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid price",
-		})
-		return
-	}
-
-	// Создаем объект обновления
-	adUpdate := models.AdUpdate{
-		Title:       title,
-		Description: description,
-		Price:       price,
-	}
-
-	var imageFilename string
-
-	// Проверяем, передается ли новое изображение
-	file, err := c.FormFile("image")
-	if err == nil {
-		// Если файл передан, обрабатываем его
-		ext := filepath.Ext(file.Filename)
-		if ext != ".png" && ext != ".jpg" && ext != ".jpeg" {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "only png/jpg/jpeg allowed",
-			})
-			return
-		}
-
-		// Генерируем уникальное имя
-		imageFilename = uuid.New().String() + ext
-		savePath := "uploads/images/" + imageFilename
-
-		// Сохраняем файл
-		if err := c.SaveUploadedFile(file, savePath); err != nil {
-			slog.Error("failed to save image", "error", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "cannot save image",
-			})
-			return
-		}
-	}
-
-	// Обновляем объявление в БД
-	err = h.repo.Update(c.Request.Context(), id, &adUpdate, imageFilename)
-	if err != nil {
-		// Удаляем сохраненный файл, если не удалось обновить запись
-		if imageFilename != "" {
-			os.Remove("uploads/images/" + imageFilename)
-		}
-		slog.Error("failed to update ad", "error", err, "id", id)
-		if err.Error() == fmt.Sprintf("ad with id %d does not exist", id) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "ad not found",
-			})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to update ad",
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
-	})
-}
-
-// ToggleAd выключает/включает объявление
-// @Summary Переключить статус объявления
-// @Description Включает или выключает объявление
-// @Tags ads
-// @Accept json
-// @Produce json
-// @Param id path int true "ID объявления"
-// @Security APIKey
-// @Success 200 {object} map[string]interface{}
-// @Failure 400 {object} ErrorResponse
-// @Failure 404 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
-// @Router /ads/{id}/toggle [patch]
-func (h *AdHandler) ToggleAd(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid ad id",
-		})
-		return
-	}
-
-	// Получаем текущий статус объявления
-	var currentStatus bool
-	err = h.repo.DB.QueryRowContext(c.Request.Context(),
-		"SELECT is_enabled FROM ads WHERE id = $1", id).Scan(&currentStatus)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "ad not found",
-			})
-			return
-		}
-		slog.Error("failed to get ad status", "error", err, "id", id)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "internal server error",
-		})
-		return
-	}
-
-	// Меняем статус на противоположный
-	newStatus := !currentStatus
-	err = h.repo.Toggle(c.Request.Context(), id, newStatus)
-	if err != nil {
-		slog.Error("failed to toggle ad", "error", err, "id", id)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to toggle ad",
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"status":  "success",
-		"enabled": newStatus,
-	})
-}
-
-// DeleteAd удаляет объявление
-// @Summary Удалить объявление
-// @Description Удаляет объявление по ID
-// @Tags ads
-// @Accept json
-// @Produce json
-// @Param id path int true "ID объявления"
-// @Security APIKey
-// @Success 200 {object} SuccessResponse
-// @Failure 400 {object} ErrorResponse
-// @Failure 404 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
-// @Router /ads/{id} [delete]
-func (h *AdHandler) DeleteAd(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid ad id",
-		})
-		return
-	}
-
-	err = h.repo.Delete(c.Request.Context(), id)
-	if err != nil {
-		slog.Error("failed to delete ad", "error", err, "id", id)
-		if err.Error() == fmt.Sprintf("ad with id %d does not exist", id) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "ad not found",
-			})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to delete ad",
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
-	})
-}
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"golang-test/internal/imageurl"
+	"golang-test/internal/middleware"
+	"golang-test/internal/models"
+	"golang-test/internal/repository"
+	"golang-test/internal/upload"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdHandler struct {
+	repo    *repository.AdRepository
+	users   *repository.UserRepository
+	uploads *upload.Service
+	urls    *imageurl.Builder
+}
+
+func NewAdHandler(repo *repository.AdRepository, users *repository.UserRepository, uploads *upload.Service, urls *imageurl.Builder) *AdHandler {
+	return &AdHandler{repo: repo, users: users, uploads: uploads, urls: urls}
+}
+
+// GetAdByID получает объявление по ID
+// @Summary Получить объявление по ID
+// @Description Возвращает информацию об объявлении по его идентификатору
+// @Tags ads
+// @Accept json
+// @Produce json
+// @Param id path int true "ID объявления"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} models.Ad
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /ads/{id} [get]
+func (h *AdHandler) GetAdByID(c *gin.Context) {
+	ad, ok := h.findAd(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, ad)
+}
+
+// findAd загружает объявление из пути запроса. При ошибке ответ клиенту отправляется здесь же.
+func (h *AdHandler) findAd(c *gin.Context) (*models.Ad, bool) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid ad id",
+		})
+		return nil, false
+	}
+
+	ad, err := h.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		slog.Error("failed to get ad", "error", err, "id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return nil, false
+	}
+
+	if ad == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "ad not found",
+		})
+		return nil, false
+	}
+
+	h.urls.Ad(ad)
+	return ad, true
+}
+
+// GetAllAds получает список объявлений
+// @Summary Получить список объявлений
+// @Description Возвращает страницу объявлений с фильтрацией и сортировкой. Поддерживает постраничную (page) и keyset (cursor) пагинацию
+// @Tags ads
+// @Accept json
+// @Produce json
+// @Param page query int false "Номер страницы" default(1)
+// @Param limit query int false "Размер страницы (максимум 100)" default(20)
+// @Param category_id query int false "ID категории; включает подкатегории"
+// @Param user_id query int false "ID пользователя"
+// @Param is_enabled query bool false "Статус объявления"
+// @Param price_min query number false "Минимальная цена"
+// @Param price_max query number false "Максимальная цена"
+// @Param attr.name query string false "Фильтр по атрибуту объявления: attr.size=M, attr.color!=red, attr.ram_gb>=8, attr.ram_gb<16; всего до 10 значений"
+// @Param created_after query string false "Создано не раньше (RFC3339 или YYYY-MM-DD)"
+// @Param created_before query string false "Создано раньше (RFC3339 или YYYY-MM-DD)"
+// @Param sort query string false "Поле сортировки" Enums(price, created_at, title)
+// @Param order query string false "Направление сортировки" Enums(asc, desc)
+// @Param cursor query string false "Курсор next_cursor/prev_cursor из предыдущего ответа (только для sort=created_at, order=desc)"
+// @Param facets query bool false "Вернуть счетчики по категориям, ценам и статусу"
+// @Param deleted query bool false "Только удаленные объявления (право ads:manage)"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} models.AdList
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /ads [get]
+func (h *AdHandler) GetAllAds(c *gin.Context) {
+	list, ok := h.listAds(c, adScope{})
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// adScope ограничивает выборку поверх параметров запроса
+type adScope struct {
+	// onlyEnabled скрывает выключенные объявления
+	onlyEnabled bool
+	// userID оставляет только объявления этого пользователя
+	userID *int
+}
+
+// listAds возвращает страницу объявлений по параметрам запроса в пределах scope.
+// При ошибке ответ клиенту отправляется здесь же.
+func (h *AdHandler) listAds(c *gin.Context, scope adScope) (*models.AdList, bool) {
+	filter, err := parseAdFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return nil, false
+	}
+	if scope.onlyEnabled {
+		enabled := true
+		filter.IsEnabled = &enabled
+	}
+	if scope.userID != nil {
+		filter.UserID = scope.userID
+	}
+
+	// Удаленные объявления видны только тем, кто может их восстановить
+	if v := c.Query("deleted"); v != "" {
+		deleted, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid deleted",
+			})
+			return nil, false
+		}
+		user := middleware.CurrentUser(c)
+		if deleted && (user == nil || !user.HasPermission(models.PermAdsManage)) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "forbidden",
+			})
+			return nil, false
+		}
+		filter.Deleted = deleted
+	}
+
+	withFacets, err := parseFacetsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return nil, false
+	}
+
+	page, err := h.repo.GetAll(c.Request.Context(), filter)
+	if err != nil {
+		slog.Error("failed to get ads", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return nil, false
+	}
+
+	for i := range page.Ads {
+		h.urls.Ad(&page.Ads[i])
+	}
+	list := adListResponse(c, filter, page)
+
+	if withFacets {
+		list.Facets, err = h.repo.Facets(c.Request.Context(), filter, "", "")
+		if err != nil {
+			slog.Error("failed to get ad facets", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "internal server error",
+			})
+			return nil, false
+		}
+	}
+
+	return &list, true
+}
+
+// SearchAds выполняет полнотекстовый поиск объявлений
+// @Summary Поиск объявлений
+// @Description Полнотекстовый поиск по заголовку и описанию. "фраза в кавычках" ищется целиком, слово* - по префиксу, -слово исключается
+// @Tags ads
+// @Accept json
+// @Produce json
+// @Param q query string true "Поисковый запрос"
+// @Param lang query string false "Языковая конфигурация (по умолчанию обе)" Enums(ru, en)
+// @Param page query int false "Номер страницы" default(1)
+// @Param limit query int false "Размер страницы (максимум 100)" default(20)
+// @Param category_id query int false "ID категории; включает подкатегории"
+// @Param price_min query number false "Минимальная цена"
+// @Param price_max query number false "Максимальная цена"
+// @Param attr.name query string false "Фильтр по атрибуту объявления: attr.size=M, attr.color!=red, attr.ram_gb>=8, attr.ram_gb<16; всего до 10 значений"
+// @Param sort query string false "Поле сортировки (по умолчанию релевантность)" Enums(price, created_at, title)
+// @Param order query string false "Направление сортировки" Enums(asc, desc)
+// @Param facets query bool false "Вернуть счетчики по категориям, ценам и статусу"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} models.AdSearchList
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /ads/search [get]
+func (h *AdHandler) SearchAds(c *gin.Context) {
+	list, ok := h.searchAds(c, false)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// searchAds выполняет поиск по параметрам запроса; onlyEnabled скрывает
+// выключенные объявления. При ошибке ответ клиенту отправляется здесь же.
+func (h *AdHandler) searchAds(c *gin.Context, onlyEnabled bool) (*models.AdSearchList, bool) {
+	q := c.Query("q")
+	if q == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "q is required",
+		})
+		return nil, false
+	}
+
+	lang := c.Query("lang")
+	if lang != "" && lang != "ru" && lang != "en" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "lang must be ru or en",
+		})
+		return nil, false
+	}
+
+	if c.Query("cursor") != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "cursor is not supported for search",
+		})
+		return nil, false
+	}
+
+	filter, err := parseAdFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return nil, false
+	}
+	if c.Query("sort") == "" {
+		filter.SortBy = "relevance"
+	}
+	if onlyEnabled {
+		enabled := true
+		filter.IsEnabled = &enabled
+	}
+
+	withFacets, err := parseFacetsParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return nil, false
+	}
+
+	results, total, err := h.repo.Search(c.Request.Context(), q, lang, filter)
+	if err != nil {
+		if err.Error() == "search query is empty" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "search query must contain letters or digits",
+			})
+			return nil, false
+		}
+		slog.Error("failed to search ads", "error", err, "q", q)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return nil, false
+	}
+
+	for i := range results {
+		h.urls.Ad(&results[i].Ad)
+	}
+
+	list := models.AdSearchList{
+		Query: q,
+		Items: results,
+		Total: total,
+		Page:  filter.Page,
+		Limit: filter.Limit,
+		Links: offsetLinks(c, filter, filter.Offset()+len(results) < total),
+	}
+
+	if withFacets {
+		list.Facets, err = h.repo.Facets(c.Request.Context(), filter, q, lang)
+		if err != nil {
+			slog.Error("failed to get ad facets", "error", err, "q", q)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "internal server error",
+			})
+			return nil, false
+		}
+	}
+
+	return &list, true
+}
+
+// CreateAd создает новое объявление
+// @Summary Создать новое объявление
+// @Description Создает новое объявление с изображениями (до 10). Первое изображение становится обложкой
+// @Tags ads
+// @Accept multipart/form-data
+// @Produce json
+// @Param images formData []file true "Изображения объявления png или jpeg; формат определяется по содержимому" collectionFormat(multi)
+// @Param image formData file false "Одно изображение (устаревшее поле, используйте images)"
+// @Param user_id formData int false "ID владельца; по умолчанию текущий пользователь, другого можно указать с правом ads:manage"
+// @Param category_id formData int true "ID категории"
+// @Param title formData string true "Заголовок объявления"
+// @Param description formData string true "Описание объявления"
+// @Param price formData number true "Цена"
+// @Param attributes formData string false "Атрибуты объявления JSON-объектом, например {\"size\": \"M\"}; проверяются по схеме атрибутов категории"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 201 {object} models.Ad
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /ads [post]
+func (h *AdHandler) CreateAd(c *gin.Context) {
+	// 1. Получаем файлы из запроса
+	files := uploadedImages(c)
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "image is required",
+		})
+		return
+	}
+
+	if len(files) > models.MaxAdImages {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("at most %d images allowed", models.MaxAdImages),
+		})
+		return
+	}
+
+	// 2. Владелец объявления - текущий пользователь. С правом ads:manage
+	// можно создать объявление от имени другого пользователя, указав user_id
+	user := middleware.CurrentUser(c)
+	userID := user.ID
+	if v := c.PostForm("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid user_id",
+			})
+			return
+		}
+		if id != user.ID && !user.HasPermission(models.PermAdsManage) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": "you are not allowed to create ads for another user",
+			})
+			return
+		}
+		userID = id
+	}
+
+	// 3. Проверяем содержимое и сохраняем файлы
+	filenames, ok := h.saveImages(c, files)
+	if !ok {
+		return
+	}
+
+	// 4. Парсим остальные данные из формы
+	categoryID, err := strconv.Atoi(c.PostForm("category_id"))
+	if err != nil {
+		h.repo.DiscardImages(c.Request.Context(), filenames)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid category_id",
+		})
+		return
+	}
+
+	title := c.PostForm("title")
+	if title == "" {
+		h.repo.DiscardImages(c.Request.Context(), filenames)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "title is required",
+		})
+		return
+	}
+
+	description := c.PostForm("description")
+	if description == "" {
+		h.repo.DiscardImages(c.Request.Context(), filenames)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "description is required",
+		})
+		return
+	}
+
+	price, err := strconv.ParseFloat(c.PostForm("price"), 64)
+	if err != nil || price < 0 {
+		h.repo.DiscardImages(c.Request.Context(), filenames)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid price",
+		})
+		return
+	}
+
+	attributes, err := formAttributes(c)
+	if err != nil {
+		h.repo.DiscardImages(c.Request.Context(), filenames)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 5. Создаем объект для создания объявления
+	adCreate := models.AdCreate{
+		UserID:      userID,
+		CategoryID:  categoryID,
+		Title:       title,
+		Description: description,
+		Price:       price,
+		Images:      filenames,
+		Attributes:  attributes,
+	}
+
+	// 6. Создаем объявление в БД
+	ad, err := h.repo.Create(c.Request.Context(), &adCreate)
+	if err != nil {
+		// Удаляем сохраненные файлы, если не удалось создать запись в БД
+		h.repo.DiscardImages(c.Request.Context(), filenames)
+		var attrErrs models.AttributeErrors
+		if errors.As(err, &attrErrs) {
+			c.JSON(http.StatusBadRequest, attributeErrorsBody(attrErrs))
+			return
+		}
+		if errors.Is(err, repository.ErrImageFileMissing) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		slog.Error("failed to create ad", "error", err)
+		errorMsg := "failed to create ad"
+		if err.Error() == fmt.Sprintf("user with id %d does not exist", userID) {
+			errorMsg = "user does not exist"
+		} else if err.Error() == fmt.Sprintf("category with id %d does not exist", categoryID) {
+			errorMsg = "category does not exist"
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": errorMsg,
+		})
+		return
+	}
+
+	h.urls.Ad(ad)
+	c.JSON(http.StatusCreated, ad)
+}
+
+// UpdateAd обновляет объявление
+// @Summary Обновить объявление
+// @Description Обновляет информацию об объявлении. Переданные изображения добавляются к существующим
+// @Tags ads
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "ID объявления"
+// @Param images formData []file false "Новые изображения png или jpeg; формат определяется по содержимому" collectionFormat(multi)
+// @Param image formData file false "Одно новое изображение (устаревшее поле, используйте images)"
+// @Param title formData string true "Заголовок объявления"
+// @Param description formData string true "Описание объявления"
+// @Param price formData number true "Цена"
+// @Param attributes formData string false "Атрибуты объявления JSON-объектом; заменяют прежние целиком и проверяются по схеме атрибутов категории. Без поля атрибуты не меняются"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /ads/{id} [put]
+func (h *AdHandler) UpdateAd(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid ad id",
+		})
+		return
+	}
+
+	if !h.authorizeAd(c, id, models.PermAdsManage) {
+		return
+	}
+
+	// Парсим данные из формы
+	title := c.PostForm("title")
+	description := c.PostForm("description")
+	priceStr := c.PostForm("price")
+
+	if title == "" || description == "" || priceStr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "title, description and price are required",
+		})
+		return
+	}
+
+	price, err := strconv.ParseFloat(priceStr, 64)
+	if err != nil || price < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid price",
+		})
+		return
+	}
+
+	attributes, err := formAttributes(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	files := uploadedImages(c)
+	if len(files) > models.MaxAdImages {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("at most %d images allowed", models.MaxAdImages),
+		})
+		return
+	}
+
+	// Сохраняем новые изображения, если они переданы
+	filenames, ok := h.saveImages(c, files)
+	if !ok {
+		return
+	}
+
+	// Создаем объект обновления
+	adUpdate := models.AdUpdate{
+		Title:       title,
+		Description: description,
+		Price:       price,
+		Images:      filenames,
+		Attributes:  attributes,
+	}
+
+	// Обновляем объявление в БД
+	err = h.repo.Update(c.Request.Context(), id, &adUpdate)
+	if err != nil {
+		// Удаляем сохраненные файлы, если не удалось обновить запись
+		h.repo.DiscardImages(c.Request.Context(), filenames)
+		var attrErrs models.AttributeErrors
+		if errors.As(err, &attrErrs) {
+			c.JSON(http.StatusBadRequest, attributeErrorsBody(attrErrs))
+			return
+		}
+		if errors.Is(err, repository.ErrImageFileMissing) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		slog.Error("failed to update ad", "error", err, "id", id)
+		if err.Error() == fmt.Sprintf("ad with id %d does not exist", id) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "ad not found",
+			})
+			return
+		}
+		if err.Error() == fmt.Sprintf("ad cannot have more than %d images", models.MaxAdImages) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to update ad",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// ToggleAd выключает/включает объявление
+// @Summary Переключить статус объявления
+// @Description Включает или выключает объявление. Доступно владельцу и пользователям с правом ads:moderate
+// @Tags ads
+// @Accept json
+// @Produce json
+// @Param id path int true "ID объявления"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /ads/{id}/toggle [patch]
+func (h *AdHandler) ToggleAd(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid ad id",
+		})
+		return
+	}
+
+	if !h.authorizeAd(c, id, models.PermAdsModerate) {
+		return
+	}
+
+	// Получаем текущий статус объявления
+	var currentStatus bool
+	err = h.repo.DB.QueryRowContext(c.Request.Context(),
+		"SELECT is_enabled FROM ads WHERE id = $1 AND deleted_at IS NULL", id).Scan(&currentStatus)
+
+	if err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "ad not found",
+			})
+			return
+		}
+		slog.Error("failed to get ad status", "error", err, "id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	// Меняем статус на противоположный
+	newStatus := !currentStatus
+	err = h.repo.Toggle(c.Request.Context(), id, newStatus)
+	if err != nil {
+		slog.Error("failed to toggle ad", "error", err, "id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to toggle ad",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "success",
+		"enabled": newStatus,
+	})
+}
+
+// DeleteAd удаляет объявление
+// @Summary Удалить объявление
+// @Description Помечает объявление удаленным. До окончательной очистки его может восстановить пользователь с правом ads:manage
+// @Tags ads
+// @Accept json
+// @Produce json
+// @Param id path int true "ID объявления"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /ads/{id} [delete]
+func (h *AdHandler) DeleteAd(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid ad id",
+		})
+		return
+	}
+
+	if !h.authorizeAd(c, id, models.PermAdsManage) {
+		return
+	}
+
+	err = h.repo.Delete(c.Request.Context(), id)
+	if err != nil {
+		slog.Error("failed to delete ad", "error", err, "id", id)
+		if err.Error() == fmt.Sprintf("ad with id %d does not exist", id) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "ad not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to delete ad",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// RestoreAd восстанавливает удаленное объявление
+// @Summary Восстановить объявление
+// @Description Снимает пометку удаления. Объявление удаленного пользователя восстанавливается только вместе с пользователем
+// @Tags ads
+// @Produce json
+// @Param id path int true "ID объявления"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} models.Ad
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /ads/{id}/restore [post]
+func (h *AdHandler) RestoreAd(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid ad id",
+		})
+		return
+	}
+
+	err = h.repo.Restore(c.Request.Context(), id)
+	if err != nil {
+		switch err.Error() {
+		case fmt.Sprintf("ad with id %d does not exist", id):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "ad not found",
+			})
+		case fmt.Sprintf("ad with id %d is not deleted", id):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "ad is not deleted",
+			})
+		case fmt.Sprintf("owner of ad with id %d is deleted", id):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "ad owner is deleted, restore the user instead",
+			})
+		default:
+			slog.Error("failed to restore ad", "error", err, "id", id)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to restore ad",
+			})
+		}
+		return
+	}
+
+	h.GetAdByID(c)
+}
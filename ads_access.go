@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"golang-test/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// authorizeAd проверяет, что текущий пользователь - владелец объявления
+// или у него есть право perm на любые объявления. При отказе ответ клиенту
+// отправляется здесь же.
+func (h *AdHandler) authorizeAd(c *gin.Context, adID int, perm string) bool {
+	ownerID, err := h.repo.GetOwnerID(c.Request.Context(), adID)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("ad with id %d does not exist", adID) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "ad not found",
+			})
+			return false
+		}
+		slog.Error("failed to get ad owner", "error", err, "id", adID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return false
+	}
+
+	user := middleware.CurrentUser(c)
+	if user.ID != ownerID && !user.HasPermission(perm) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "you are not allowed to modify this ad",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"golang-test/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxAttributeFilters ограничивает количество значений в условиях attr.<имя>
+// одного запроса
+const maxAttributeFilters = 10
+
+// formAttributes читает JSON-объект атрибутов из поля формы attributes.
+// Если поле не передано, возвращается nil.
+func formAttributes(c *gin.Context) (models.AdAttributes, error) {
+	v, ok := c.GetPostForm("attributes")
+	if !ok {
+		return nil, nil
+	}
+
+	// Числа разбираются как json.Number, чтобы целые значения не теряли точность
+	dec := json.NewDecoder(strings.NewReader(v))
+	dec.UseNumber()
+	attributes := models.AdAttributes{}
+	if err := dec.Decode(&attributes); err != nil || dec.More() {
+		return nil, errors.New("attributes must be a JSON object")
+	}
+	if attributes == nil {
+		// Значение null
+		attributes = models.AdAttributes{}
+	}
+	return attributes, nil
+}
+
+// attributeErrorsBody - тело ответа 400, если атрибуты не прошли проверку по схеме категории
+func attributeErrorsBody(errs models.AttributeErrors) gin.H {
+	return gin.H{
+		"error":   "invalid attributes",
+		"details": errs,
+	}
+}
+
+// parseAttributeFilters разбирает условия на атрибуты из параметров запроса:
+//
+//	attr.size=M           равно; при нескольких значениях подходит любое
+//	attr.color!=red       не равно ни одному из значений; атрибут должен быть задан
+//	attr.ram_gb>=8        не меньше (также <=)
+//	attr.ram_gb>8         больше (также <)
+//
+// В строке запроса >= и <= выглядят как ключ "attr.ram_gb>" со значением,
+// а > и < - как ключ "attr.ram_gb>8" без значения.
+func parseAttributeFilters(c *gin.Context) ([]models.AttributeFilter, error) {
+	query := c.Request.URL.Query()
+
+	// Ключи сортируются, чтобы условия и текст SQL не зависели от порядка обхода map
+	var keys []string
+	for key := range query {
+		if strings.HasPrefix(key, "attr.") {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	var filters []models.AttributeFilter
+	total := 0
+	for _, key := range keys {
+		values := query[key]
+		if total += len(values); total > maxAttributeFilters {
+			return nil, fmt.Errorf("at most %d attribute filter values allowed", maxAttributeFilters)
+		}
+		expr := strings.TrimPrefix(key, "attr.")
+
+		var f models.AttributeFilter
+		switch {
+		case strings.HasSuffix(expr, ">"):
+			f = models.AttributeFilter{Name: strings.TrimSuffix(expr, ">"), Op: models.AttrOpGte, Values: values}
+		case strings.HasSuffix(expr, "<"):
+			f = models.AttributeFilter{Name: strings.TrimSuffix(expr, "<"), Op: models.AttrOpLte, Values: values}
+		case strings.HasSuffix(expr, "!"):
+			f = models.AttributeFilter{Name: strings.TrimSuffix(expr, "!"), Op: models.AttrOpNe, Values: values}
+		case strings.ContainsAny(expr, "<>"):
+			i := strings.IndexAny(expr, "<>")
+			if slices.ContainsFunc(values, func(v string) bool { return v != "" }) {
+				return nil, fmt.Errorf("invalid attribute filter %s", key)
+			}
+			f = models.AttributeFilter{Name: expr[:i], Op: expr[i : i+1], Values: []string{expr[i+1:]}}
+		default:
+			f = models.AttributeFilter{Name: expr, Op: models.AttrOpEq, Values: values}
+		}
+
+		if !models.IsAttributeName(f.Name) {
+			return nil, fmt.Errorf("invalid attribute filter %s", key)
+		}
+		if slices.Contains(f.Values, "") {
+			return nil, fmt.Errorf("attribute filter %s requires a value", key)
+		}
+
+		if f.Op == models.AttrOpEq || f.Op == models.AttrOpNe {
+			filters = append(filters, f)
+			continue
+		}
+
+		// Каждое сравнение - отдельное условие с одним числом
+		for _, v := range f.Values {
+			n, err := strconv.ParseFloat(v, 64)
+			if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+				return nil, fmt.Errorf("attribute filter %s requires a number", key)
+			}
+			filters = append(filters, models.AttributeFilter{
+				Name:   f.Name,
+				Op:     f.Op,
+				Values: []string{strconv.FormatFloat(n, 'f', -1, 64)},
+			})
+		}
+	}
+
+	return filters, nil
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"golang-test/internal/models"
+	"golang-test/internal/repository"
+	"golang-test/internal/upload"
+
+	"github.com/gin-gonic/gin"
+)
+
+// uploadedImages возвращает файлы из поля images и устаревшего поля image
+func uploadedImages(c *gin.Context) []*multipart.FileHeader {
+	form, err := c.MultipartForm()
+	if err != nil {
+		return nil
+	}
+	files := append([]*multipart.FileHeader{}, form.File["images"]...)
+	return append(files, form.File["image"]...)
+}
+
+// saveImages проверяет и сохраняет файлы через сервис загрузки.
+// При ошибке ответ клиенту отправляется здесь же.
+func (h *AdHandler) saveImages(c *gin.Context, files []*multipart.FileHeader) ([]string, bool) {
+	filenames, err := h.uploads.SaveImages(c.Request.Context(), files)
+	if err != nil {
+		var verrs upload.ValidationErrors
+		if errors.As(err, &verrs) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "invalid image",
+				"details": verrs,
+			})
+			return nil, false
+		}
+		slog.Error("failed to save images", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "cannot save image",
+		})
+		return nil, false
+	}
+
+	return filenames, true
+}
+
+// imageErrorResponse отвечает клиенту по ошибке репозитория при работе с изображениями
+func imageErrorResponse(c *gin.Context, err error, adID, imageID int) {
+	if errors.Is(err, repository.ErrImageFileMissing) {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	switch err.Error() {
+	case fmt.Sprintf("ad with id %d does not exist", adID):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "ad not found",
+		})
+	case fmt.Sprintf("image with id %d does not exist", imageID):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "image not found",
+		})
+	case fmt.Sprintf("ad cannot have more than %d images", models.MaxAdImages),
+		"image_ids must list every image of the ad exactly once",
+		"ad must have at least one image":
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+	default:
+		slog.Error("failed to change ad images", "error", err, "ad_id", adID, "image_id", imageID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+	}
+}
+
+// adImageIDs разбирает ID объявления и, если он есть в пути, ID изображения
+func adImageIDs(c *gin.Context) (int, int, bool) {
+	adID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid ad id",
+		})
+		return 0, 0, false
+	}
+
+	if c.Param("imageId") == "" {
+		return adID, 0, true
+	}
+
+	imageID, err := strconv.Atoi(c.Param("imageId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid image id",
+		})
+		return 0, 0, false
+	}
+
+	return adID, imageID, true
+}
+
+// AddAdImages добавляет изображения к объявлению
+// @Summary Добавить изображения
+// @Description Загружает изображения в конец списка. У объявления может быть не более 10 изображений
+// @Tags ads
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "ID объявления"
+// @Param images formData []file true "Изображения png или jpeg; формат определяется по содержимому" collectionFormat(multi)
+// @Security BearerAuth
+// @Security APIKey
+// @Success 201 {array} models.AdImage
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /ads/{id}/images [post]
+func (h *AdHandler) AddAdImages(c *gin.Context) {
+	adID, _, ok := adImageIDs(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorizeAd(c, adID, models.PermAdsManage) {
+		return
+	}
+
+	files := uploadedImages(c)
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "images are required",
+		})
+		return
+	}
+	if len(files) > models.MaxAdImages {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("at most %d images allowed", models.MaxAdImages),
+		})
+		return
+	}
+
+	filenames, ok := h.saveImages(c, files)
+	if !ok {
+		return
+	}
+
+	images, err := h.repo.AddImages(c.Request.Context(), adID, filenames)
+	if err != nil {
+		h.repo.DiscardImages(c.Request.Context(), filenames)
+		imageErrorResponse(c, err, adID, 0)
+		return
+	}
+
+	h.urls.Images(images)
+	c.JSON(http.StatusCreated, images)
+}
+
+// ReorderAdImages меняет порядок изображений
+// @Summary Изменить порядок изображений
+// @Description Принимает ID всех изображений объявления в новом порядке
+// @Tags ads
+// @Accept json
+// @Produce json
+// @Param id path int true "ID объявления"
+// @Param order body models.AdImagesOrder true "Новый порядок изображений"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {array} models.AdImage
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /ads/{id}/images/order [put]
+func (h *AdHandler) ReorderAdImages(c *gin.Context) {
+	adID, _, ok := adImageIDs(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorizeAd(c, adID, models.PermAdsManage) {
+		return
+	}
+
+	var order models.AdImagesOrder
+	if err := c.ShouldBindJSON(&order); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := h.repo.ReorderImages(c.Request.Context(), adID, order.ImageIDs); err != nil {
+		imageErrorResponse(c, err, adID, 0)
+		return
+	}
+
+	images, err := h.repo.GetImages(c.Request.Context(), adID)
+	if err != nil {
+		imageErrorResponse(c, err, adID, 0)
+		return
+	}
+
+	h.urls.Images(images)
+	c.JSON(http.StatusOK, images)
+}
+
+// SetAdCoverImage выбирает обложку объявления
+// @Summary Выбрать обложку
+// @Description Делает изображение обложкой объявления
+// @Tags ads
+// @Accept json
+// @Produce json
+// @Param id path int true "ID объявления"
+// @Param imageId path int true "ID изображения"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /ads/{id}/images/{imageId}/cover [patch]
+func (h *AdHandler) SetAdCoverImage(c *gin.Context) {
+	adID, imageID, ok := adImageIDs(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorizeAd(c, adID, models.PermAdsManage) {
+		return
+	}
+
+	if err := h.repo.SetCoverImage(c.Request.Context(), adID, imageID); err != nil {
+		imageErrorResponse(c, err, adID, imageID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// DeleteAdImage удаляет изображение объявления
+// @Summary Удалить изображение
+// @Description Удаляет одно изображение. Последнее изображение объявления удалить нельзя
+// @Tags ads
+// @Accept json
+// @Produce json
+// @Param id path int true "ID объявления"
+// @Param imageId path int true "ID изображения"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /ads/{id}/images/{imageId} [delete]
+func (h *AdHandler) DeleteAdImage(c *gin.Context) {
+	adID, imageID, ok := adImageIDs(c)
+	if !ok {
+		return
+	}
+
+	if !h.authorizeAd(c, adID, models.PermAdsManage) {
+		return
+	}
+
+	if err := h.repo.DeleteImage(c.Request.Context(), adID, imageID); err != nil {
+		imageErrorResponse(c, err, adID, imageID)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+
+	"golang-test/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPublicAdByID возвращает включенное объявление без авторизации
+// @Summary Публичное объявление
+// @Description Возвращает включенное объявление без контактных данных продавца
+// @Tags public
+// @Produce json
+// @Param id path int true "ID объявления"
+// @Success 200 {object} models.PublicAd
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /public/ads/{id} [get]
+func (h *AdHandler) GetPublicAdByID(c *gin.Context) {
+	ad, ok := h.findAd(c)
+	if !ok {
+		return
+	}
+
+	// Выключенное объявление для посетителей не существует
+	if !ad.IsEnabled {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "ad not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewPublicAd(ad))
+}
+
+// GetPublicAds возвращает включенные объявления без авторизации
+// @Summary Публичный список объявлений
+// @Description Страница включенных объявлений без контактных данных продавцов. Фильтры и пагинация - как в GET /ads, кроме is_enabled
+// @Tags public
+// @Produce json
+// @Param page query int false "Номер страницы" default(1)
+// @Param limit query int false "Размер страницы (максимум 100)" default(20)
+// @Param category_id query int false "ID категории; включает подкатегории"
+// @Param user_id query int false "ID продавца"
+// @Param price_min query number false "Минимальная цена"
+// @Param price_max query number false "Максимальная цена"
+// @Param attr.name query string false "Фильтр по атрибуту объявления: attr.size=M, attr.color!=red, attr.ram_gb>=8, attr.ram_gb<16; всего до 10 значений"
+// @Param created_after query string false "Создано не раньше (RFC3339 или YYYY-MM-DD)"
+// @Param created_before query string false "Создано раньше (RFC3339 или YYYY-MM-DD)"
+// @Param sort query string false "Поле сортировки" Enums(price, created_at, title)
+// @Param order query string false "Направление сортировки" Enums(asc, desc)
+// @Param cursor query string false "Курсор next_cursor/prev_cursor из предыдущего ответа (только для sort=created_at, order=desc)"
+// @Param facets query bool false "Вернуть счетчики по категориям и ценам"
+// @Success 200 {object} models.PublicAdList
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /public/ads [get]
+func (h *AdHandler) GetPublicAds(c *gin.Context) {
+	list, ok := h.listAds(c, adScope{onlyEnabled: true})
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewPublicAdList(list))
+}
+
+// SearchPublicAds ищет среди включенных объявлений без авторизации
+// @Summary Публичный поиск объявлений
+// @Description Полнотекстовый поиск среди включенных объявлений. Параметры - как в GET /ads/search
+// @Tags public
+// @Produce json
+// @Param q query string true "Поисковый запрос"
+// @Param lang query string false "Языковая конфигурация (по умолчанию обе)" Enums(ru, en)
+// @Param page query int false "Номер страницы" default(1)
+// @Param limit query int false "Размер страницы (максимум 100)" default(20)
+// @Param category_id query int false "ID категории; включает подкатегории"
+// @Param price_min query number false "Минимальная цена"
+// @Param price_max query number false "Максимальная цена"
+// @Param attr.name query string false "Фильтр по атрибуту объявления: attr.size=M, attr.color!=red, attr.ram_gb>=8, attr.ram_gb<16; всего до 10 значений"
+// @Param sort query string false "Поле сортировки (по умолчанию релевантность)" Enums(price, created_at, title)
+// @Param order query string false "Направление сортировки" Enums(asc, desc)
+// @Param facets query bool false "Вернуть счетчики по категориям и ценам"
+// @Success 200 {object} models.PublicAdSearchList
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /public/ads/search [get]
+func (h *AdHandler) SearchPublicAds(c *gin.Context) {
+	list, ok := h.searchAds(c, true)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.NewPublicAdSearchList(list))
+}
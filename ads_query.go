@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"golang-test/internal/models"
+	"golang-test/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAdsLimit = 20
+	maxAdsLimit     = 100
+)
+
+// parseAdFilter разбирает параметры запроса списка объявлений
+func parseAdFilter(c *gin.Context) (models.AdFilter, error) {
+	filter := models.AdFilter{
+		Page:      1,
+		Limit:     defaultAdsLimit,
+		SortBy:    "created_at",
+		SortOrder: "desc",
+	}
+
+	if v := c.Query("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return filter, fmt.Errorf("invalid page")
+		}
+		filter.Page = page
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAdsLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxAdsLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if v := c.Query("category_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid category_id")
+		}
+		filter.CategoryID = &id
+	}
+
+	if v := c.Query("user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid user_id")
+		}
+		filter.UserID = &id
+	}
+
+	if v := c.Query("is_enabled"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid is_enabled")
+		}
+		filter.IsEnabled = &enabled
+	}
+
+	if v := c.Query("price_min"); v != "" {
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil || price < 0 {
+			return filter, fmt.Errorf("invalid price_min")
+		}
+		filter.PriceMin = &price
+	}
+
+	if v := c.Query("price_max"); v != "" {
+		price, err := strconv.ParseFloat(v, 64)
+		if err != nil || price < 0 {
+			return filter, fmt.Errorf("invalid price_max")
+		}
+		filter.PriceMax = &price
+	}
+
+	if filter.PriceMin != nil && filter.PriceMax != nil && *filter.PriceMin > *filter.PriceMax {
+		return filter, fmt.Errorf("price_min must not exceed price_max")
+	}
+
+	if v := c.Query("created_after"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid created_after")
+		}
+		filter.CreatedAfter = &t
+	}
+
+	if v := c.Query("created_before"); v != "" {
+		t, err := parseTimeParam(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid created_before")
+		}
+		filter.CreatedBefore = &t
+	}
+
+	if v := c.Query("sort"); v != "" {
+		switch v {
+		case "price", "created_at", "title":
+			filter.SortBy = v
+		default:
+			return filter, fmt.Errorf("sort must be one of price, created_at, title")
+		}
+	}
+
+	if v := c.Query("order"); v != "" {
+		if v != "asc" && v != "desc" {
+			return filter, fmt.Errorf("order must be asc or desc")
+		}
+		filter.SortOrder = v
+	}
+
+	attributes, err := parseAttributeFilters(c)
+	if err != nil {
+		return filter, err
+	}
+	filter.Attributes = attributes
+
+	if v := c.Query("cursor"); v != "" {
+		if c.Query("page") != "" {
+			return filter, fmt.Errorf("cursor and page cannot be used together")
+		}
+		if !supportsCursor(filter) {
+			return filter, fmt.Errorf("cursor requires sort=created_at and order=desc")
+		}
+		cursor, err := models.DecodeAdCursor(v)
+		if err != nil {
+			return filter, err
+		}
+		filter.Cursor = cursor
+		filter.Page = 0
+	}
+
+	return filter, nil
+}
+
+// supportsCursor проверяет, что сортировка совпадает с порядком ключа курсора (created_at, id)
+func supportsCursor(filter models.AdFilter) bool {
+	return filter.SortBy == "created_at" && filter.SortOrder == "desc"
+}
+
+// parseFacetsParam разбирает флаг facets
+func parseFacetsParam(c *gin.Context) (bool, error) {
+	v := c.Query("facets")
+	if v == "" {
+		return false, nil
+	}
+	withFacets, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("invalid facets")
+	}
+	return withFacets, nil
+}
+
+// parseTimeParam принимает дату в формате RFC3339 или YYYY-MM-DD
+func parseTimeParam(v string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", v)
+}
+
+// adListResponse собирает конверт ответа со ссылками и курсорами
+func adListResponse(c *gin.Context, filter models.AdFilter, page *repository.AdPage) models.AdList {
+	list := models.AdList{
+		Items: page.Ads,
+		Total: page.Total,
+		Page:  filter.Page,
+		Limit: filter.Limit,
+	}
+
+	if supportsCursor(filter) && len(page.Ads) > 0 {
+		first, last := page.Ads[0], page.Ads[len(page.Ads)-1]
+		prev := models.AdCursor{CreatedAt: first.CreatedAt, ID: first.ID, Backward: true}
+		next := models.AdCursor{CreatedAt: last.CreatedAt, ID: last.ID}
+
+		var hasNext, hasPrev bool
+		switch {
+		case filter.Cursor == nil:
+			hasNext, hasPrev = page.HasMore, filter.Page > 1
+		case filter.Cursor.Backward:
+			hasNext, hasPrev = true, page.HasMore
+		default:
+			hasNext, hasPrev = page.HasMore, true
+		}
+
+		if hasNext {
+			list.NextCursor = next.Encode()
+		}
+		if hasPrev {
+			list.PrevCursor = prev.Encode()
+		}
+	}
+
+	if filter.Cursor != nil {
+		list.Links.Self = linkWithQuery(c, map[string]string{})
+		if list.NextCursor != "" {
+			list.Links.Next = linkWithQuery(c, map[string]string{"cursor": list.NextCursor})
+		}
+		if list.PrevCursor != "" {
+			list.Links.Prev = linkWithQuery(c, map[string]string{"cursor": list.PrevCursor})
+		}
+		return list
+	}
+
+	list.Links = offsetLinks(c, filter, page.HasMore)
+	return list
+}
+
+// offsetLinks строит ссылки постраничной навигации
+func offsetLinks(c *gin.Context, filter models.AdFilter, hasMore bool) models.PageLinks {
+	pageLink := func(p int) string {
+		return linkWithQuery(c, map[string]string{
+			"page":  strconv.Itoa(p),
+			"limit": strconv.Itoa(filter.Limit),
+		})
+	}
+
+	links := models.PageLinks{Self: pageLink(filter.Page)}
+	if hasMore {
+		links.Next = pageLink(filter.Page + 1)
+	}
+	if filter.Page > 1 {
+		links.Prev = pageLink(filter.Page - 1)
+	}
+	return links
+}
+
+// linkWithQuery возвращает адрес текущего запроса с замененными параметрами
+func linkWithQuery(c *gin.Context, params map[string]string) string {
+	u := *c.Request.URL
+	q := u.Query()
+	for k, v := range params {
+		q.Set(k, v)
+	}
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
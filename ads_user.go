@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUserAds получает объявления пользователя
+// @Summary Объявления пользователя
+// @Description Возвращает страницу объявлений пользователя. Фильтры, сортировка и пагинация - как в GET /ads; параметр user_id берется из пути
+// @Tags users
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param page query int false "Номер страницы" default(1)
+// @Param limit query int false "Размер страницы (максимум 100)" default(20)
+// @Param category_id query int false "ID категории; включает подкатегории"
+// @Param is_enabled query bool false "Статус объявления"
+// @Param price_min query number false "Минимальная цена"
+// @Param price_max query number false "Максимальная цена"
+// @Param attr.name query string false "Фильтр по атрибуту объявления: attr.size=M, attr.color!=red, attr.ram_gb>=8, attr.ram_gb<16; всего до 10 значений"
+// @Param created_after query string false "Создано не раньше (RFC3339 или YYYY-MM-DD)"
+// @Param created_before query string false "Создано раньше (RFC3339 или YYYY-MM-DD)"
+// @Param sort query string false "Поле сортировки" Enums(price, created_at, title)
+// @Param order query string false "Направление сортировки" Enums(asc, desc)
+// @Param cursor query string false "Курсор next_cursor/prev_cursor из предыдущего ответа (только для sort=created_at, order=desc)"
+// @Param facets query bool false "Вернуть счетчики по категориям, ценам и статусу"
+// @Param deleted query bool false "Только удаленные объявления (право ads:manage)"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} models.AdList
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users/{id}/ads [get]
+func (h *AdHandler) GetUserAds(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	// Для несуществующего пользователя отвечаем 404, а не пустым списком
+	if _, err := h.users.GetByID(c.Request.Context(), userID); err != nil {
+		if err.Error() == fmt.Sprintf("user with id %d does not exist", userID) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "user not found",
+			})
+			return
+		}
+		slog.Error("failed to get user", "error", err, "id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	list, ok := h.listAds(c, adScope{userID: &userID})
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
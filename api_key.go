@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// Области действия API-ключа
+const (
+	// ScopeReadOnly разрешает только чтение (GET и HEAD)
+	ScopeReadOnly = "read-only"
+	// ScopeAdsWrite разрешает управлять объявлениями владельца ключа
+	ScopeAdsWrite = "ads:write"
+	// ScopeAdmin дает ключу все права владельца
+	ScopeAdmin = "admin"
+)
+
+type APIKey struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+type APIKeyCreate struct {
+	Name      string     `json:"name" binding:"required,min=1,max=100"`
+	Scopes    []string   `json:"scopes" binding:"required,min=1,dive,oneof=read-only ads:write admin"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// APIKeyCreated - ответ на создание ключа; Key больше нигде не возвращается
+type APIKeyCreated struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type APIKeyAuditEntry struct {
+	ID        int64     `json:"id"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	IP        string    `json:"ip"`
+	CreatedAt time.Time `json:"created_at"`
+}
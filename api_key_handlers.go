@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang-test/internal/auth"
+	"golang-test/internal/middleware"
+	"golang-test/internal/models"
+	"golang-test/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditLimit = 50
+	maxAuditLimit     = 500
+)
+
+type APIKeyHandler struct {
+	repo *repository.APIKeyRepository
+}
+
+func NewAPIKeyHandler(repo *repository.APIKeyRepository) *APIKeyHandler {
+	return &APIKeyHandler{repo: repo}
+}
+
+// requireSession запрещает управлять ключами с помощью другого ключа,
+// иначе утекший ключ позволил бы выпускать новые
+func requireSession(c *gin.Context) bool {
+	if middleware.CurrentAPIKeyID(c) != 0 {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "api keys cannot be managed with an api key",
+		})
+		return false
+	}
+	return true
+}
+
+// authorizeAPIKey проверяет, что ключ принадлежит текущему пользователю
+// или у пользователя есть право users:manage
+func (h *APIKeyHandler) authorizeAPIKey(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid api key id",
+		})
+		return 0, false
+	}
+
+	ownerID, err := h.repo.GetOwnerID(c.Request.Context(), id)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("api key with id %d does not exist", id) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "api key not found",
+			})
+			return 0, false
+		}
+		slog.Error("failed to get api key owner", "error", err, "id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return 0, false
+	}
+
+	user := middleware.CurrentUser(c)
+	if ownerID != user.ID && !user.HasPermission(models.PermUsersManage) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "you are not allowed to access this api key",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// CreateAPIKey выпускает API-ключ текущего пользователя
+// @Summary Создать API-ключ
+// @Description Ключ возвращается только в этом ответе. Области: read-only - только чтение, ads:write - управление своими объявлениями, admin - все права владельца
+// @Tags api-keys
+// @Accept json
+// @Produce json
+// @Param key body models.APIKeyCreate true "Параметры ключа"
+// @Security BearerAuth
+// @Success 201 {object} models.APIKeyCreated
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api-keys [post]
+func (h *APIKeyHandler) CreateAPIKey(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+
+	var create models.APIKeyCreate
+	if err := c.ShouldBindJSON(&create); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if create.ExpiresAt != nil && !create.ExpiresAt.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "expires_at must be in the future",
+		})
+		return
+	}
+
+	// Ключ не может получить область, которой нет смысла давать без соответствующих прав
+	user := middleware.CurrentUser(c)
+	for _, scope := range create.Scopes {
+		if (scope == models.ScopeAdmin && !user.HasPermission(models.PermRolesManage)) ||
+			(scope == models.ScopeAdsWrite && !user.HasPermission(models.PermAdsWrite)) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": fmt.Sprintf("you are not allowed to create keys with scope %s", scope),
+			})
+			return
+		}
+	}
+
+	key, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		slog.Error("failed to generate api key", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	apiKey, err := h.repo.Create(c.Request.Context(), user.ID, &create, prefix, hash)
+	if err != nil {
+		slog.Error("failed to create api key", "error", err, "user_id", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	slog.Info("api key created", "api_key_id", apiKey.ID, "user_id", user.ID, "scopes", apiKey.Scopes)
+	c.JSON(http.StatusCreated, models.APIKeyCreated{APIKey: *apiKey, Key: key})
+}
+
+// GetAPIKeys возвращает ключи текущего пользователя
+// @Summary Список API-ключей
+// @Tags api-keys
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.APIKey
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api-keys [get]
+func (h *APIKeyHandler) GetAPIKeys(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	keys, err := h.repo.GetByUser(c.Request.Context(), user.ID)
+	if err != nil {
+		slog.Error("failed to get api keys", "error", err, "user_id", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeAPIKey отзывает API-ключ
+// @Summary Отозвать API-ключ
+// @Description Отзывает ключ владельца; с правом users:manage - любой ключ
+// @Tags api-keys
+// @Produce json
+// @Param id path int true "ID ключа"
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api-keys/{id} [delete]
+func (h *APIKeyHandler) RevokeAPIKey(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+
+	id, ok := h.authorizeAPIKey(c)
+	if !ok {
+		return
+	}
+
+	if err := h.repo.Revoke(c.Request.Context(), id); err != nil {
+		slog.Error("failed to revoke api key", "error", err, "id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	slog.Info("api key revoked", "api_key_id", id, "by_user_id", middleware.CurrentUser(c).ID)
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// GetAPIKeyAudit возвращает журнал запросов ключа
+// @Summary Журнал API-ключа
+// @Description Запросы, выполненные с ключом, от новых к старым
+// @Tags api-keys
+// @Produce json
+// @Param id path int true "ID ключа"
+// @Param limit query int false "Количество записей (максимум 500)" default(50)
+// @Param before query int false "Вернуть записи с ID меньше указанного"
+// @Security BearerAuth
+// @Success 200 {array} models.APIKeyAuditEntry
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api-keys/{id}/audit [get]
+func (h *APIKeyHandler) GetAPIKeyAudit(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+
+	id, ok := h.authorizeAPIKey(c)
+	if !ok {
+		return
+	}
+
+	limit := defaultAuditLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAuditLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": fmt.Sprintf("limit must be between 1 and %d", maxAuditLimit),
+			})
+			return
+		}
+		limit = n
+	}
+
+	var before int64
+	if v := c.Query("before"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid before",
+			})
+			return
+		}
+		before = n
+	}
+
+	entries, err := h.repo.AuditLog(c.Request.Context(), id, before, limit)
+	if err != nil {
+		slog.Error("failed to get api key audit log", "error", err, "id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
@@ -0,0 +1,169 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"golang-test/internal/models"
+)
+
+type APIKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+const apiKeyColumns = `id, user_id, name, prefix, array_to_string(scopes, ','), expires_at, last_used_at, revoked_at, created_at`
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }, key *models.APIKey, extra ...interface{}) error {
+	var scopes string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	dest := append([]interface{}{&key.ID, &key.UserID, &key.Name, &key.Prefix, &scopes, &expiresAt, &lastUsedAt, &revokedAt, &key.CreatedAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	key.Scopes = splitList(scopes)
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return nil
+}
+
+// Create сохраняет новый ключ; сам ключ в базу не попадает
+func (r *APIKeyRepository) Create(ctx context.Context, userID int, create *models.APIKeyCreate, prefix, hash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := scanAPIKey(r.db.QueryRowContext(ctx, `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+apiKeyColumns,
+		userID, create.Name, prefix, hash, create.Scopes, create.ExpiresAt), &key)
+	if err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetActive находит действующий ключ по открытой части и возвращает его хеш
+// и владельца с ролями и правами. Если ключа нет, он отозван или истек, возвращается nil.
+func (r *APIKeyRepository) GetActive(ctx context.Context, prefix string) (*models.APIKey, string, *models.User, error) {
+	var key models.APIKey
+	var hash string
+	var user models.User
+	err := scanAPIKey(r.db.QueryRowContext(ctx, `
+		SELECT k.`+apiKeyColumns+`, k.key_hash, u.name, u.email, u.email_verified_at, u.created_at
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.prefix = $1
+		  AND k.revoked_at IS NULL
+		  AND u.deleted_at IS NULL
+		  AND (k.expires_at IS NULL OR k.expires_at > NOW())
+	`, prefix), &key, &hash, &user.Name, &user.Email, &user.EmailVerifiedAt, &user.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", nil, nil
+		}
+		return nil, "", nil, err
+	}
+
+	user.ID = key.UserID
+	if err := loadUserAccess(ctx, r.db, &user); err != nil {
+		return nil, "", nil, err
+	}
+	return &key, hash, &user, nil
+}
+
+// GetByUser возвращает ключи пользователя, включая отозванные
+func (r *APIKeyRepository) GetByUser(ctx context.Context, userID int) ([]models.APIKey, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []models.APIKey{}
+	for rows.Next() {
+		var key models.APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// GetOwnerID возвращает ID владельца ключа
+func (r *APIKeyRepository) GetOwnerID(ctx context.Context, id int) (int, error) {
+	var userID int
+	err := r.db.QueryRowContext(ctx, "SELECT user_id FROM api_keys WHERE id = $1", id).Scan(&userID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("api key with id %d does not exist", id)
+		}
+		return 0, err
+	}
+	return userID, nil
+}
+
+// Revoke отзывает ключ; повторный отзыв не меняет время отзыва
+func (r *APIKeyRepository) Revoke(ctx context.Context, id int) error {
+	res, err := r.db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("api key with id %d does not exist", id)
+	}
+	return nil
+}
+
+// LogUsage записывает запрос в журнал ключа и обновляет время последнего использования
+func (r *APIKeyRepository) LogUsage(ctx context.Context, id int, method, path string, status int, ip string) error {
+	_, err := r.db.ExecContext(ctx, `
+		WITH k AS (
+			UPDATE api_keys SET last_used_at = NOW() WHERE id = $1
+		)
+		INSERT INTO api_key_audit_log (api_key_id, method, path, status, ip)
+		VALUES ($1, $2, $3, $4, $5)
+	`, id, method, path, status, ip)
+	return err
+}
+
+// AuditLog возвращает последние записи журнала ключа, начиная с самых новых.
+// before - ID записи, с которой продолжать (0 - с начала).
+func (r *APIKeyRepository) AuditLog(ctx context.Context, id int, before int64, limit int) ([]models.APIKeyAuditEntry, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, method, path, status, ip, created_at
+		FROM api_key_audit_log
+		WHERE api_key_id = $1 AND ($2 = 0 OR id < $2)
+		ORDER BY id DESC
+		LIMIT $3
+	`, id, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.APIKeyAuditEntry{}
+	for rows.Next() {
+		var e models.APIKeyAuditEntry
+		if err := rows.Scan(&e.ID, &e.Method, &e.Path, &e.Status, &e.IP, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
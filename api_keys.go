@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+)
+
+// apiKeyPrefix отличает API-ключи от других секретов, например при поиске утечек в логах
+const apiKeyPrefix = "gtk"
+
+// NewAPIKey создает ключ вида gtk_<prefix>_<secret>. В базе хранятся prefix
+// для поиска и хеш всего ключа для проверки.
+func NewAPIKey() (key, prefix, hash string, err error) {
+	b := make([]byte, 4+32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", "", err
+	}
+	prefix = hex.EncodeToString(b[:4])
+	key = apiKeyPrefix + "_" + prefix + "_" + hex.EncodeToString(b[4:])
+	return key, prefix, HashToken(key), nil
+}
+
+// ParseAPIKey возвращает открытую часть ключа
+func ParseAPIKey(key string) (prefix string, ok bool) {
+	parts := strings.Split(key, "_")
+	if len(parts) != 3 || parts[0] != apiKeyPrefix || parts[1] == "" || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
+
+// CheckAPIKey сравнивает хеш ключа с сохраненным за постоянное время
+func CheckAPIKey(key, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashToken(key)), []byte(hash)) == 1
+}
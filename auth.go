@@ -1,21 +1,162 @@
-package middleware
-
-import (
-	"net/http"
-	"os"
-
-	"github.com/gin-gonic/gin"
-)
-
-func AuthMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		key := c.GetHeader("Key")
-		if key == "" || key != os.Getenv("API_KEY") {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
-				"error": "unauthorized",
-			})
-			return
-		}
-		c.Next()
-	}
-}
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+
+	"golang-test/internal/auth"
+	"golang-test/internal/models"
+	"golang-test/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	userKey    = "user"
+	sessionKey = "session_id"
+	apiKeyKey  = "api_key_id"
+	scopesKey  = "api_key_scopes"
+)
+
+// APIKeyHeader - заголовок, в котором передается API-ключ
+const APIKeyHeader = "X-API-Key"
+
+// AuthMiddleware проверяет access-токен из заголовка Authorization: Bearer <токен>
+// или API-ключ из заголовка X-API-Key и кладет пользователя в контекст gin (см. CurrentUser)
+func AuthMiddleware(tokens *auth.Tokens, sessions *repository.SessionRepository, apiKeys *repository.APIKeyRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if key := c.GetHeader(APIKeyHeader); key != "" {
+			apiKeyAuth(c, apiKeys, key)
+			return
+		}
+
+		header := c.GetHeader("Authorization")
+		token, ok := strings.CutPrefix(header, "Bearer ")
+		if !ok || token == "" {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "unauthorized",
+			})
+			return
+		}
+
+		claims, err := tokens.ParseAccess(token)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "unauthorized",
+			})
+			return
+		}
+
+		userID, _ := claims.UserID()
+		user, err := sessions.User(c.Request.Context(), userID, claims.SessionID)
+		if err != nil {
+			slog.Error("failed to load session user", "error", err, "user_id", userID)
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+				"error": "internal server error",
+			})
+			return
+		}
+		// Сессия отозвана или пользователь удален
+		if user == nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+				"error": "unauthorized",
+			})
+			return
+		}
+
+		c.Set(userKey, user)
+		c.Set(sessionKey, claims.SessionID)
+		c.Next()
+	}
+}
+
+// apiKeyAuth проверяет API-ключ, ограничивает права владельца областями
+// действия ключа и записывает запрос в журнал ключа
+func apiKeyAuth(c *gin.Context, apiKeys *repository.APIKeyRepository, key string) {
+	prefix, ok := auth.ParseAPIKey(key)
+	if !ok {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	apiKey, hash, user, err := apiKeys.GetActive(c.Request.Context(), prefix)
+	if err != nil {
+		slog.Error("failed to load api key", "error", err, "prefix", prefix)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+	if apiKey == nil || !auth.CheckAPIKey(key, hash) {
+		slog.Warn("invalid api key", "prefix", prefix, "ip", c.ClientIP())
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+			"error": "unauthorized",
+		})
+		return
+	}
+
+	// Журнал пишется и для отклоненных запросов, поэтому после обработки,
+	// когда известен код ответа
+	defer func() {
+		// Запрос уже обработан, запись не должна зависеть от отключения клиента
+		ctx := context.WithoutCancel(c.Request.Context())
+		if err := apiKeys.LogUsage(ctx, apiKey.ID, c.Request.Method, c.Request.URL.Path, c.Writer.Status(), c.ClientIP()); err != nil {
+			slog.Error("failed to write api key audit log", "error", err, "api_key_id", apiKey.ID)
+		}
+	}()
+
+	readOnly := !slices.Contains(apiKey.Scopes, models.ScopeAdmin) && !slices.Contains(apiKey.Scopes, models.ScopeAdsWrite)
+	if readOnly && c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+			"error": "api key is read-only",
+		})
+		return
+	}
+
+	user.Permissions = scopePermissions(apiKey.Scopes, user.Permissions)
+
+	c.Set(userKey, user)
+	c.Set(apiKeyKey, apiKey.ID)
+	c.Set(scopesKey, apiKey.Scopes)
+	c.Next()
+}
+
+// scopePermissions возвращает права владельца, разрешенные областями действия ключа
+func scopePermissions(scopes, permissions []string) []string {
+	if slices.Contains(scopes, models.ScopeAdmin) {
+		return permissions
+	}
+	allowed := []string{}
+	if slices.Contains(scopes, models.ScopeAdsWrite) && slices.Contains(permissions, models.PermAdsWrite) {
+		allowed = append(allowed, models.PermAdsWrite)
+	}
+	return allowed
+}
+
+// CurrentUser возвращает пользователя, аутентифицированного AuthMiddleware
+func CurrentUser(c *gin.Context) *models.User {
+	user, _ := c.Get(userKey)
+	u, _ := user.(*models.User)
+	return u
+}
+
+// CurrentSessionID возвращает ID сессии текущего access-токена
+func CurrentSessionID(c *gin.Context) string {
+	return c.GetString(sessionKey)
+}
+
+// CurrentAPIKeyID возвращает ID API-ключа, которым аутентифицирован запрос, или 0
+func CurrentAPIKeyID(c *gin.Context) int {
+	return c.GetInt(apiKeyKey)
+}
+
+// CurrentAPIKeyHasScope проверяет, что запрос аутентифицирован API-ключом
+// с областью действия scope
+func CurrentAPIKeyHasScope(c *gin.Context, scope string) bool {
+	return slices.Contains(c.GetStringSlice(scopesKey), scope)
+}
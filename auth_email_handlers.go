@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang-test/internal/auth"
+	"golang-test/internal/mailer"
+	"golang-test/internal/middleware"
+	"golang-test/internal/models"
+	"golang-test/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SendVerification выдает токен подтверждения текущего email пользователя
+// и отправляет ссылку на него. Используется также UserHandler при смене email.
+func (h *AuthHandler) SendVerification(ctx context.Context, user *models.User) error {
+	token, hash, err := auth.NewEmailToken()
+	if err != nil {
+		return err
+	}
+	ttl := h.tokens.EmailVerificationTTL()
+	if err := h.emailTokens.Create(ctx, user, models.TokenEmailVerification, hash, time.Now().Add(ttl), h.tokens.EmailInterval()); err != nil {
+		return err
+	}
+
+	link := h.baseURL + "/auth/verify-email?token=" + url.QueryEscape(token)
+	return h.mail.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Подтверждение email",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\n"+
+			"Чтобы подтвердить адрес, перейдите по ссылке:\n%s\n\n"+
+			"Ссылка действует %s. Если вы не регистрировались и не меняли адрес, просто проигнорируйте это письмо.\n",
+			user.Name, link, ttl),
+	})
+}
+
+// sendPasswordReset выдает токен сброса пароля и отправляет его на email пользователя
+func (h *AuthHandler) sendPasswordReset(ctx context.Context, user *models.User) error {
+	token, hash, err := auth.NewEmailToken()
+	if err != nil {
+		return err
+	}
+	ttl := h.tokens.PasswordResetTTL()
+	if err := h.emailTokens.Create(ctx, user, models.TokenPasswordReset, hash, time.Now().Add(ttl), h.tokens.EmailInterval()); err != nil {
+		return err
+	}
+
+	return h.mail.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "Сброс пароля",
+		Body: fmt.Sprintf("Здравствуйте, %s!\n\n"+
+			"Код для сброса пароля:\n%s\n\n"+
+			"Отправьте его вместе с новым паролем на POST %s/auth/password-reset/confirm. "+
+			"Код действует %s и подходит один раз. Все открытые сессии будут завершены.\n"+
+			"Если вы не запрашивали сброс, просто проигнорируйте это письмо.\n",
+			user.Name, token, h.baseURL, ttl),
+	})
+}
+
+// VerifyEmail подтверждает email по ссылке из письма
+// @Summary Подтверждение email
+// @Description Погашает токен из письма. Токен одноразовый и недействителен после смены email
+// @Tags auth
+// @Produce json
+// @Param token query string true "Токен из письма"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/verify-email [get]
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	token := c.Query("token")
+	if token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "token is required",
+		})
+		return
+	}
+
+	userID, err := h.emailTokens.VerifyEmail(c.Request.Context(), auth.HashToken(token))
+	if err != nil {
+		if err.Error() == "invalid or expired token" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid or expired token",
+			})
+			return
+		}
+		slog.Error("failed to verify email", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	slog.Info("email verified", "user_id", userID)
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// ResendVerification повторно отправляет письмо подтверждения
+// @Summary Повторное письмо подтверждения
+// @Description Отправляет новую ссылку на текущий email; прежние ссылки перестают действовать. Повторно письмо можно запросить не чаще EMAIL_INTERVAL
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} SuccessResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/verify-email/resend [post]
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "email is already verified",
+		})
+		return
+	}
+
+	if err := h.SendVerification(c.Request.Context(), user); err != nil {
+		if errors.Is(err, repository.ErrEmailTokenTooSoon) {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error": "verification email was sent recently, try again later",
+			})
+			return
+		}
+		slog.Error("failed to send verification email", "error", err, "user_id", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to send email",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// RequestPasswordReset отправляет письмо для сброса пароля
+// @Summary Запрос сброса пароля
+// @Description Если пользователь с таким email существует, отправляет ему одноразовый код. Ответ не зависит от существования адреса. На один адрес письмо отправляется не чаще EMAIL_INTERVAL, более частые запросы пропускаются
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.PasswordResetRequest true "Email"
+// @Success 202 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/password-reset [post]
+func (h *AuthHandler) RequestPasswordReset(c *gin.Context) {
+	var request models.PasswordResetRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	user, _, err := h.users.GetCredentials(c.Request.Context(), request.Email)
+	if err != nil {
+		slog.Error("failed to get user credentials", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	// Письмо отправляется в фоне, чтобы время ответа не выдавало
+	// зарегистрированные email. Число фоновых отправок ограничено:
+	// при переполнении запрос пропускается, ответ от этого не меняется.
+	if user != nil {
+		select {
+		case h.mailSlots <- struct{}{}:
+			go func() {
+				defer func() { <-h.mailSlots }()
+				ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+				defer cancel()
+				err := h.sendPasswordReset(ctx, user)
+				switch {
+				case errors.Is(err, repository.ErrEmailTokenTooSoon):
+					slog.Info("password reset email skipped: sent recently", "user_id", user.ID)
+				case err != nil:
+					slog.Error("failed to send password reset email", "error", err, "user_id", user.ID)
+				}
+			}()
+		default:
+			slog.Warn("password reset email skipped: too many pending emails", "user_id", user.ID)
+		}
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status": "success",
+	})
+}
+
+// ConfirmPasswordReset задает новый пароль по коду из письма
+// @Summary Сброс пароля
+// @Description Погашает код из письма, меняет пароль и завершает все сессии пользователя
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body models.PasswordResetConfirm true "Код и новый пароль"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/password-reset/confirm [post]
+func (h *AuthHandler) ConfirmPasswordReset(c *gin.Context) {
+	var confirm models.PasswordResetConfirm
+	if err := c.ShouldBindJSON(&confirm); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	passwordHash, err := auth.HashPassword(confirm.Password)
+	if err != nil {
+		slog.Error("failed to hash password", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	userID, err := h.emailTokens.ResetPassword(c.Request.Context(), auth.HashToken(confirm.Token), passwordHash)
+	if err != nil {
+		if err.Error() == "invalid or expired token" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid or expired token",
+			})
+			return
+		}
+		slog.Error("failed to reset password", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	slog.Info("password reset", "user_id", userID)
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
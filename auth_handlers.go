@@ -0,0 +1,312 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang-test/internal/auth"
+	"golang-test/internal/mailer"
+	"golang-test/internal/middleware"
+	"golang-test/internal/models"
+	"golang-test/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AuthHandler struct {
+	users       *repository.UserRepository
+	sessions    *repository.SessionRepository
+	emailTokens *repository.EmailTokenRepository
+	tokens      *auth.Tokens
+	mail        mailer.Mailer
+	// baseURL - внешний адрес сервера для ссылок в письмах
+	baseURL string
+	// mailSlots ограничивает число писем, отправляемых в фоне
+	mailSlots chan struct{}
+}
+
+// maxPendingMails - сколько писем одновременно может отправляться в фоне
+const maxPendingMails = 16
+
+func NewAuthHandler(users *repository.UserRepository, sessions *repository.SessionRepository, emailTokens *repository.EmailTokenRepository, tokens *auth.Tokens, mail mailer.Mailer, baseURL string) *AuthHandler {
+	return &AuthHandler{
+		users:       users,
+		sessions:    sessions,
+		emailTokens: emailTokens,
+		tokens:      tokens,
+		mail:        mail,
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		mailSlots:   make(chan struct{}, maxPendingMails),
+	}
+}
+
+// Register регистрирует нового пользователя
+// @Summary Регистрация
+// @Description Создает пользователя с паролем и отправляет письмо для подтверждения email. Для получения токенов используйте /auth/login
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param user body models.UserCreate true "Данные пользователя"
+// @Success 201 {object} models.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/register [post]
+func (h *AuthHandler) Register(c *gin.Context) {
+	var userCreate models.UserCreate
+	if err := c.ShouldBindJSON(&userCreate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	passwordHash, err := auth.HashPassword(userCreate.Password)
+	if err != nil {
+		slog.Error("failed to hash password", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to create user",
+		})
+		return
+	}
+
+	user, err := h.users.Create(c.Request.Context(), &userCreate, passwordHash)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("user with email %s already exists", userCreate.Email) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "user with this email already exists",
+			})
+			return
+		}
+		slog.Error("failed to register user", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to create user",
+		})
+		return
+	}
+
+	// Регистрация не откатывается из-за почты: письмо можно запросить повторно
+	if err := h.SendVerification(c.Request.Context(), user); err != nil {
+		slog.Error("failed to send verification email", "error", err, "user_id", user.ID)
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// Login выдает пару токенов по email и паролю
+// @Summary Вход
+// @Description Проверяет email и пароль, открывает сессию и возвращает access- и refresh-токены
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param credentials body models.UserLogin true "Email и пароль"
+// @Success 200 {object} models.TokenPair
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/login [post]
+func (h *AuthHandler) Login(c *gin.Context) {
+	var login models.UserLogin
+	if err := c.ShouldBindJSON(&login); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	user, passwordHash, err := h.users.GetCredentials(c.Request.Context(), login.Email)
+	if err != nil {
+		slog.Error("failed to get user credentials", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	// Для несуществующего пользователя хеш пустой, но проверка все равно
+	// выполняется, чтобы время ответа не выдавало зарегистрированные email
+	if err := auth.CheckPassword(passwordHash, login.Password); err != nil || user == nil {
+		if err != nil && !errors.Is(err, auth.ErrInvalidPassword) {
+			slog.Error("failed to check password", "error", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"error": "invalid email or password",
+		})
+		return
+	}
+
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		slog.Error("failed to generate refresh token", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	sessionID, err := h.sessions.Create(c.Request.Context(), user.ID, refreshHash, time.Now().Add(h.tokens.RefreshTTL()))
+	if err != nil {
+		slog.Error("failed to create session", "error", err, "user_id", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	h.tokenResponse(c, user.ID, sessionID, refreshToken)
+}
+
+// Refresh обменивает refresh-токен на новую пару токенов
+// @Summary Обновление токенов
+// @Description Погашает refresh-токен и выдает новую пару. Повторное использование погашенного токена отзывает сессию
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param token body models.RefreshRequest true "Refresh-токен"
+// @Success 200 {object} models.TokenPair
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/refresh [post]
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	refreshToken, refreshHash, err := auth.NewRefreshToken()
+	if err != nil {
+		slog.Error("failed to generate refresh token", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	userID, sessionID, err := h.sessions.Rotate(c.Request.Context(), auth.HashToken(req.RefreshToken), refreshHash, time.Now().Add(h.tokens.RefreshTTL()))
+	if err != nil {
+		switch err.Error() {
+		case "invalid refresh token":
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid refresh token",
+			})
+		case "refresh token reuse detected":
+			slog.Warn("refresh token reuse detected, session revoked")
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": "invalid refresh token",
+			})
+		default:
+			slog.Error("failed to rotate refresh token", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "internal server error",
+			})
+		}
+		return
+	}
+
+	h.tokenResponse(c, userID, sessionID, refreshToken)
+}
+
+// Logout завершает текущую сессию
+// @Summary Выход
+// @Description Отзывает текущую сессию: ее access- и refresh-токены больше не принимаются
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	if middleware.CurrentSessionID(c) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "request is not authenticated with a session",
+		})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	if err := h.sessions.Revoke(c.Request.Context(), user.ID, middleware.CurrentSessionID(c)); err != nil {
+		slog.Error("failed to revoke session", "error", err, "user_id", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// LogoutAll завершает все сессии пользователя
+// @Summary Выход со всех устройств
+// @Description Отзывает все сессии текущего пользователя
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /auth/logout/all [post]
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	if middleware.CurrentSessionID(c) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "request is not authenticated with a session",
+		})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	if err := h.sessions.RevokeAll(c.Request.Context(), user.ID); err != nil {
+		slog.Error("failed to revoke sessions", "error", err, "user_id", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// Me возвращает текущего пользователя
+// @Summary Текущий пользователь
+// @Tags auth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} models.User
+// @Failure 401 {object} ErrorResponse
+// @Router /auth/me [get]
+func (h *AuthHandler) Me(c *gin.Context) {
+	c.JSON(http.StatusOK, middleware.CurrentUser(c))
+}
+
+// tokenResponse подписывает access-токен и отправляет пару токенов
+func (h *AuthHandler) tokenResponse(c *gin.Context, userID int, sessionID, refreshToken string) {
+	accessToken, err := h.tokens.IssueAccess(userID, sessionID)
+	if err != nil {
+		slog.Error("failed to sign access token", "error", err, "user_id", userID)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(h.tokens.AccessTTL().Seconds()),
+	})
+}
@@ -0,0 +1,317 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+
+	"golang-test/internal/models"
+	"golang-test/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CategoryHandler struct {
+	repo *repository.CategoryRepository
+}
+
+func NewCategoryHandler(repo *repository.CategoryRepository) *CategoryHandler {
+	return &CategoryHandler{repo: repo}
+}
+
+// GetCategories возвращает список категорий
+// @Summary Список категорий
+// @Description Возвращает все категории по алфавиту
+// @Tags categories
+// @Produce json
+// @Success 200 {array} models.Category
+// @Failure 500 {object} ErrorResponse
+// @Router /categories [get]
+func (h *CategoryHandler) GetCategories(c *gin.Context) {
+	categories, err := h.repo.GetAll(c.Request.Context())
+	if err != nil {
+		slog.Error("failed to get categories", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+// GetCategoryTree возвращает дерево категорий
+// @Summary Дерево категорий
+// @Description Возвращает категории верхнего уровня с вложенными подкатегориями; на каждом уровне категории идут по алфавиту
+// @Tags categories
+// @Produce json
+// @Success 200 {array} models.CategoryNode
+// @Failure 500 {object} ErrorResponse
+// @Router /categories/tree [get]
+func (h *CategoryHandler) GetCategoryTree(c *gin.Context) {
+	tree, err := h.repo.Tree(c.Request.Context())
+	if err != nil {
+		slog.Error("failed to get category tree", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+// GetCategory возвращает категорию по ID
+// @Summary Получить категорию
+// @Description Возвращает категорию с путем от корня дерева (breadcrumbs)
+// @Tags categories
+// @Produce json
+// @Param id path int true "ID категории"
+// @Success 200 {object} models.Category
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /categories/{id} [get]
+func (h *CategoryHandler) GetCategory(c *gin.Context) {
+	id, ok := parseCategoryID(c)
+	if !ok {
+		return
+	}
+
+	category, err := h.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("category with id %d does not exist", id) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "category not found",
+			})
+			return
+		}
+		slog.Error("failed to get category", "error", err, "id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// CreateCategory создает категорию
+// @Summary Создать категорию
+// @Description Требует права categories:manage. attributes задает схему атрибутов объявлений категории: имя (латиница в нижнем регистре, цифры, _), тип string, int, number, bool или enum, список значений enum, обязательность required и единица измерения unit для чисел
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param category body models.CategoryCreate true "Данные категории"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 201 {object} models.Category
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /categories [post]
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	var create models.CategoryCreate
+	if err := c.ShouldBindJSON(&create); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err := create.Attributes.Check(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	category, err := h.repo.Create(c.Request.Context(), &create)
+	if err != nil {
+		switch {
+		case err.Error() == fmt.Sprintf("category with name %s already exists", create.Name):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "category with this name already exists",
+			})
+		case create.ParentID != nil && err.Error() == fmt.Sprintf("parent category with id %d does not exist", *create.ParentID):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "parent category does not exist",
+			})
+		default:
+			slog.Error("failed to create category", "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to create category",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory изменяет категорию
+// @Summary Изменить категорию
+// @Description Требует права categories:manage. Смена parent_id переносит категорию вместе с подкатегориями; переносить категорию под саму себя или своего потомка нельзя. Пустой parent_id делает категорию корневой. attributes заменяет схему атрибутов целиком; если атрибуты объявлений категории (включая удаленные) не подходят под новую схему, возвращается 409 с их ID
+// @Tags categories
+// @Accept json
+// @Produce json
+// @Param id path int true "ID категории"
+// @Param category body models.CategoryUpdate true "Данные категории"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} models.Category
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /categories/{id} [put]
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
+	id, ok := parseCategoryID(c)
+	if !ok {
+		return
+	}
+
+	var update models.CategoryUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if err := update.Attributes.Check(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	category, err := h.repo.Update(c.Request.Context(), id, &update)
+	if err != nil {
+		var invalid *repository.CategoryAdsInvalidError
+		switch {
+		case errors.As(err, &invalid):
+			adsInvalidResponse(c, invalid)
+		case err.Error() == fmt.Sprintf("category with id %d does not exist", id):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "category not found",
+			})
+		case err.Error() == fmt.Sprintf("category with name %s already exists", update.Name):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "category with this name already exists",
+			})
+		case update.ParentID != nil && err.Error() == fmt.Sprintf("parent category with id %d does not exist", *update.ParentID):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "parent category does not exist",
+			})
+		case err.Error() == "category cannot be moved under itself or its descendant":
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+		default:
+			slog.Error("failed to update category", "error", err, "id", id)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to update category",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, category)
+}
+
+// DeleteCategory удаляет категорию
+// @Summary Удалить категорию
+// @Description Требует права categories:manage. Категорию с подкатегориями удалить нельзя. Категорию с объявлениями (включая удаленные) можно удалить, только перенеся их в другую категорию через reassign_to, иначе возвращается 409. Переносимые объявления должны подходить под схему атрибутов целевой категории, иначе возвращается 409 с их ID
+// @Tags categories
+// @Produce json
+// @Param id path int true "ID категории"
+// @Param reassign_to query int false "Категория, в которую переносятся объявления"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /categories/{id} [delete]
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	id, ok := parseCategoryID(c)
+	if !ok {
+		return
+	}
+
+	reassignTo := 0
+	if v := c.Query("reassign_to"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid reassign_to",
+			})
+			return
+		}
+		reassignTo = n
+	}
+
+	err := h.repo.Delete(c.Request.Context(), id, reassignTo)
+	if err != nil {
+		var hasAds *repository.CategoryHasAdsError
+		var invalid *repository.CategoryAdsInvalidError
+		switch {
+		case errors.As(err, &hasAds):
+			c.JSON(http.StatusConflict, gin.H{
+				"error":     "category has ads, pass reassign_to to move them",
+				"ads_count": hasAds.Ads,
+			})
+		case errors.As(err, &invalid):
+			adsInvalidResponse(c, invalid)
+		case err.Error() == fmt.Sprintf("category with id %d does not exist", id):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "category not found",
+			})
+		case err.Error() == fmt.Sprintf("category with id %d has subcategories", id):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "category has subcategories, move or delete them first",
+			})
+		case err.Error() == fmt.Sprintf("reassign target category with id %d does not exist", reassignTo):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid reassign_to category",
+			})
+		default:
+			slog.Error("failed to delete category", "error", err, "id", id)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to delete category",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// adsInvalidResponse отвечает 409, если объявления не подходят под схему атрибутов категории
+func adsInvalidResponse(c *gin.Context, invalid *repository.CategoryAdsInvalidError) {
+	c.JSON(http.StatusConflict, gin.H{
+		"error":     "ads do not match the category attributes",
+		"ad_ids":    invalid.AdIDs,
+		"ads_count": invalid.Count,
+	})
+}
+
+// parseCategoryID читает ID категории из пути запроса.
+// При ошибке ответ клиенту отправляется здесь же.
+func parseCategoryID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid category id",
+		})
+		return 0, false
+	}
+	return id, true
+}
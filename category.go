@@ -1,7 +1,41 @@
-package models
-
-type Category struct {
-	ID            int    `json:"id"`
-	Name          string `json:"name"`
-	ExtraProperty string `json:"extra_property"`
-}
+package models
+
+type Category struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Attributes - схема атрибутов объявлений категории; в данных
+	// объявления категория приходит без схемы
+	Attributes AttributeSchema `json:"attributes,omitempty"`
+	// ParentID пуст у категорий верхнего уровня
+	ParentID *int `json:"parent_id"`
+	// Breadcrumbs - путь от корня дерева до категории включительно;
+	// заполняется при запросе одной категории или объявления
+	Breadcrumbs []CategoryRef `json:"breadcrumbs,omitempty"`
+}
+
+// CategoryRef - краткая ссылка на категорию
+type CategoryRef struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// CategoryNode - категория с подкатегориями в дереве категорий
+type CategoryNode struct {
+	Category
+	Children []CategoryNode `json:"children"`
+}
+
+type CategoryCreate struct {
+	Name       string          `json:"name" binding:"required,min=1,max=50"`
+	Attributes AttributeSchema `json:"attributes" binding:"max=50"`
+	ParentID   *int            `json:"parent_id" binding:"omitempty,min=1"`
+}
+
+// CategoryUpdate заменяет данные категории; смена parent_id переносит
+// категорию вместе со всеми подкатегориями. Новой схеме атрибутов должны
+// подходить все объявления категории.
+type CategoryUpdate struct {
+	Name       string          `json:"name" binding:"required,min=1,max=50"`
+	Attributes AttributeSchema `json:"attributes" binding:"max=50"`
+	ParentID   *int            `json:"parent_id" binding:"omitempty,min=1"`
+}
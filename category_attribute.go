@@ -0,0 +1,254 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// Типы атрибутов категории
+const (
+	AttrString = "string"
+	AttrInt    = "int"
+	AttrNumber = "number"
+	AttrBool   = "bool"
+	// AttrEnum - строка из списка Enum
+	AttrEnum = "enum"
+)
+
+// AttrTypes - все допустимые типы атрибутов
+var AttrTypes = []string{AttrString, AttrInt, AttrNumber, AttrBool, AttrEnum}
+
+// MaxAttrStringLength - максимальная длина строкового значения атрибута
+const MaxAttrStringLength = 200
+
+// Имена атрибутов используются в параметрах фильтра attr.<имя>,
+// поэтому состоят только из латинских букв, цифр и подчеркиваний
+var attributeNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// IsAttributeName проверяет имя атрибута
+func IsAttributeName(name string) bool {
+	return attributeNamePattern.MatchString(name)
+}
+
+// CategoryAttribute описывает атрибут объявлений категории
+type CategoryAttribute struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Enum     []string `json:"enum,omitempty"`
+	Required bool     `json:"required"`
+	// Unit - единица измерения числового атрибута, например GB
+	Unit string `json:"unit,omitempty"`
+}
+
+// AttributeSchema - схема атрибутов объявлений категории; хранится в JSONB
+type AttributeSchema []CategoryAttribute
+
+func (s AttributeSchema) Value() (driver.Value, error) {
+	if s == nil {
+		return "[]", nil
+	}
+	data, err := json.Marshal(s)
+	return string(data), err
+}
+
+func (s *AttributeSchema) Scan(src interface{}) error {
+	return scanJSON(src, s)
+}
+
+// Check проверяет саму схему: имена, типы, списки значений и единицы измерения
+func (s AttributeSchema) Check() error {
+	seen := make(map[string]bool, len(s))
+	for _, attr := range s {
+		if !IsAttributeName(attr.Name) {
+			return fmt.Errorf("invalid attribute name %q: use lowercase latin letters, digits and underscores", attr.Name)
+		}
+		if seen[attr.Name] {
+			return fmt.Errorf("duplicate attribute %q", attr.Name)
+		}
+		seen[attr.Name] = true
+
+		if !slices.Contains(AttrTypes, attr.Type) {
+			return fmt.Errorf("attribute %q: type must be one of: %s", attr.Name, strings.Join(AttrTypes, ", "))
+		}
+		if attr.Type == AttrEnum {
+			if len(attr.Enum) == 0 {
+				return fmt.Errorf("attribute %q: enum values are required", attr.Name)
+			}
+			values := make(map[string]bool, len(attr.Enum))
+			for _, v := range attr.Enum {
+				if v == "" || values[v] {
+					return fmt.Errorf("attribute %q: enum values must be unique and not empty", attr.Name)
+				}
+				values[v] = true
+			}
+		} else if len(attr.Enum) > 0 {
+			return fmt.Errorf("attribute %q: enum values are allowed only for enum type", attr.Name)
+		}
+		if attr.Unit != "" && attr.Type != AttrInt && attr.Type != AttrNumber {
+			return fmt.Errorf("attribute %q: unit is allowed only for numeric types", attr.Name)
+		}
+	}
+	return nil
+}
+
+// AdAttributes - значения атрибутов объявления; хранятся в JSONB
+type AdAttributes map[string]interface{}
+
+func (a AdAttributes) Value() (driver.Value, error) {
+	if a == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(a)
+	return string(data), err
+}
+
+func (a *AdAttributes) Scan(src interface{}) error {
+	return scanJSON(src, a)
+}
+
+// scanJSON читает значение JSONB-колонки
+func scanJSON(src interface{}, dst interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, dst)
+	case string:
+		return json.Unmarshal([]byte(v), dst)
+	case nil:
+		return nil
+	default:
+		return fmt.Errorf("cannot scan %T into JSON", src)
+	}
+}
+
+// AttributeError описывает, почему значение атрибута не прошло проверку
+type AttributeError struct {
+	Attribute string `json:"attribute"`
+	Message   string `json:"message"`
+}
+
+// AttributeErrors - ошибки проверки всех атрибутов объявления
+type AttributeErrors []AttributeError
+
+func (e AttributeErrors) Error() string {
+	if len(e) == 0 {
+		return "invalid attributes"
+	}
+	return fmt.Sprintf("%s: %s", e[0].Attribute, e[0].Message)
+}
+
+// Validate проверяет значения атрибутов по схеме и возвращает их
+// в нормализованном виде: целые числа как int64, остальные числа как float64.
+// Если есть ошибки, возвращается AttributeErrors со всеми ошибками.
+func (s AttributeSchema) Validate(values AdAttributes) (AdAttributes, error) {
+	var errs AttributeErrors
+	result := make(AdAttributes, len(values))
+
+	for _, attr := range s {
+		v, ok := values[attr.Name]
+		if !ok || v == nil {
+			if attr.Required {
+				errs = append(errs, AttributeError{Attribute: attr.Name, Message: "is required"})
+			}
+			continue
+		}
+
+		normalized, msg := attr.normalize(v)
+		if msg != "" {
+			errs = append(errs, AttributeError{Attribute: attr.Name, Message: msg})
+			continue
+		}
+		result[attr.Name] = normalized
+	}
+
+	// Ключи сортируются, чтобы ошибки шли в предсказуемом порядке
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if !slices.ContainsFunc(s, func(attr CategoryAttribute) bool { return attr.Name == name }) {
+			errs = append(errs, AttributeError{Attribute: name, Message: "is not defined for this category"})
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return result, nil
+}
+
+// normalize приводит значение к типу атрибута; при ошибке возвращает ее описание
+func (attr CategoryAttribute) normalize(v interface{}) (interface{}, string) {
+	switch attr.Type {
+	case AttrString:
+		s, ok := v.(string)
+		if !ok {
+			return nil, "must be a string"
+		}
+		if len([]rune(s)) > MaxAttrStringLength {
+			return nil, fmt.Sprintf("must be at most %d characters", MaxAttrStringLength)
+		}
+		return s, ""
+	case AttrEnum:
+		s, ok := v.(string)
+		if !ok || !slices.Contains(attr.Enum, s) {
+			return nil, "must be one of: " + strings.Join(attr.Enum, ", ")
+		}
+		return s, ""
+	case AttrBool:
+		b, ok := v.(bool)
+		if !ok {
+			return nil, "must be a boolean"
+		}
+		return b, ""
+	case AttrInt, AttrNumber:
+		f, ok := toFloat(v)
+		if !ok || math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, "must be a number"
+		}
+		if attr.Type == AttrNumber {
+			return f, ""
+		}
+		if f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+			return nil, "must be an integer"
+		}
+		return int64(f), ""
+	}
+	return nil, "has unknown type"
+}
+
+// toFloat принимает числа, разобранные encoding/json как float64 или json.Number
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// Операторы фильтра по атрибутам
+const (
+	AttrOpEq  = "="
+	AttrOpNe  = "!="
+	AttrOpGt  = ">"
+	AttrOpGte = ">="
+	AttrOpLt  = "<"
+	AttrOpLte = "<="
+)
+
+// AttributeFilter - условие на атрибут объявления из параметра attr.<имя>.
+// Для = и != значений может быть несколько: подходит любое из них.
+type AttributeFilter struct {
+	Name   string
+	Op     string
+	Values []string
+}
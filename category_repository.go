@@ -0,0 +1,388 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"golang-test/internal/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// CategoryHasAdsError возвращается при удалении категории, у которой есть
+// объявления, если не указана категория для их переноса
+type CategoryHasAdsError struct {
+	Ads int
+}
+
+func (e *CategoryHasAdsError) Error() string {
+	return fmt.Sprintf("category has %d ads", e.Ads)
+}
+
+// CategoryAdsInvalidError возвращается, если объявления не подходят под схему
+// атрибутов категории после ее изменения или переноса объявлений в нее
+type CategoryAdsInvalidError struct {
+	// AdIDs - ID первых maxInvalidAdIDs неподходящих объявлений
+	AdIDs []int
+	Count int
+}
+
+func (e *CategoryAdsInvalidError) Error() string {
+	return fmt.Sprintf("%d ads do not match category attributes", e.Count)
+}
+
+// maxInvalidAdIDs ограничивает число ID в CategoryAdsInvalidError
+const maxInvalidAdIDs = 100
+
+// checkAdAttributes проверяет атрибуты всех объявлений категории categoryID,
+// включая удаленные, по схеме schema
+func checkAdAttributes(ctx context.Context, tx *sql.Tx, categoryID int, schema models.AttributeSchema) error {
+	// Строки объявлений не блокируются: объявление, которое меняют одновременно,
+	// ждет блокировку категории и проверит атрибуты уже по новой схеме
+	rows, err := tx.QueryContext(ctx, "SELECT id, attributes FROM ads WHERE category_id = $1 ORDER BY id", categoryID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	invalid := &CategoryAdsInvalidError{}
+	for rows.Next() {
+		var id int
+		var attributes models.AdAttributes
+		if err := rows.Scan(&id, &attributes); err != nil {
+			return err
+		}
+		if _, err := schema.Validate(attributes); err != nil {
+			if invalid.Count < maxInvalidAdIDs {
+				invalid.AdIDs = append(invalid.AdIDs, id)
+			}
+			invalid.Count++
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if invalid.Count > 0 {
+		return invalid
+	}
+	return nil
+}
+
+// categorySubtree - запрос ID категории с плейсхолдером $%d и всех ее потомков
+const categorySubtree = `
+	WITH RECURSIVE subtree AS (
+		SELECT id FROM categories WHERE id = $%d
+		UNION ALL
+		SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+	)
+	SELECT id FROM subtree`
+
+// categoryBreadcrumbs возвращает путь от корня дерева до категории id включительно
+func categoryBreadcrumbs(ctx context.Context, q queryer, id int) ([]models.CategoryRef, error) {
+	rows, err := q.QueryContext(ctx, `
+		WITH RECURSIVE path AS (
+			SELECT id, name, parent_id, 0 AS depth FROM categories WHERE id = $1
+			UNION ALL
+			SELECT c.id, c.name, c.parent_id, p.depth + 1
+			FROM categories c
+			JOIN path p ON c.id = p.parent_id
+		)
+		SELECT id, name FROM path ORDER BY depth DESC
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	path := []models.CategoryRef{}
+	for rows.Next() {
+		var ref models.CategoryRef
+		if err := rows.Scan(&ref.ID, &ref.Name); err != nil {
+			return nil, err
+		}
+		path = append(path, ref)
+	}
+	return path, rows.Err()
+}
+
+// categoryAttributes возвращает схему атрибутов категории и блокирует ее
+// строку, чтобы схема не изменилась до конца транзакции
+func categoryAttributes(ctx context.Context, tx *sql.Tx, id int) (models.AttributeSchema, error) {
+	var schema models.AttributeSchema
+	err := tx.QueryRowContext(ctx, "SELECT attributes FROM categories WHERE id = $1 FOR SHARE", id).Scan(&schema)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("category with id %d does not exist", id)
+		}
+		return nil, err
+	}
+	return schema, nil
+}
+
+type CategoryRepository struct {
+	db *sql.DB
+}
+
+func NewCategoryRepository(db *sql.DB) *CategoryRepository {
+	return &CategoryRepository{db: db}
+}
+
+// GetAll возвращает все категории по алфавиту
+func (r *CategoryRepository) GetAll(ctx context.Context) ([]models.Category, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name, attributes, parent_id FROM categories ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		var category models.Category
+		if err := rows.Scan(&category.ID, &category.Name, &category.Attributes, &category.ParentID); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+	return categories, rows.Err()
+}
+
+// Tree возвращает дерево категорий; на каждом уровне категории идут по алфавиту
+func (r *CategoryRepository) Tree(ctx context.Context) ([]models.CategoryNode, error) {
+	categories, err := r.GetAll(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[int][]models.Category)
+	var roots []models.Category
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+		} else {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		}
+	}
+
+	var build func([]models.Category) []models.CategoryNode
+	build = func(level []models.Category) []models.CategoryNode {
+		nodes := make([]models.CategoryNode, 0, len(level))
+		for _, category := range level {
+			nodes = append(nodes, models.CategoryNode{Category: category, Children: build(children[category.ID])})
+		}
+		return nodes
+	}
+	return build(roots), nil
+}
+
+// GetByID возвращает категорию с путем от корня дерева
+func (r *CategoryRepository) GetByID(ctx context.Context, id int) (*models.Category, error) {
+	var category models.Category
+	err := r.db.QueryRowContext(ctx, "SELECT id, name, attributes, parent_id FROM categories WHERE id = $1", id).
+		Scan(&category.ID, &category.Name, &category.Attributes, &category.ParentID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("category with id %d does not exist", id)
+		}
+		return nil, err
+	}
+
+	if category.Breadcrumbs, err = categoryBreadcrumbs(ctx, r.db, id); err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+func (r *CategoryRepository) Create(ctx context.Context, create *models.CategoryCreate) (*models.Category, error) {
+	category := models.Category{Name: create.Name, Attributes: create.Attributes, ParentID: create.ParentID}
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO categories (name, attributes, parent_id) VALUES ($1, $2, $3)
+		RETURNING id
+	`, create.Name, create.Attributes, create.ParentID).Scan(&category.ID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("category with name %s already exists", create.Name)
+		}
+		if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+			return nil, fmt.Errorf("parent category with id %d does not exist", *create.ParentID)
+		}
+		return nil, err
+	}
+
+	if category.Breadcrumbs, err = categoryBreadcrumbs(ctx, r.db, category.ID); err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// Update изменяет категорию. Категорию можно перенести вместе с подкатегориями
+// под другого родителя, но не под саму себя или своего потомка. Новая схема
+// атрибутов должна подходить всем объявлениям категории, иначе возвращается
+// CategoryAdsInvalidError.
+func (r *CategoryRepository) Update(ctx context.Context, id int, update *models.CategoryUpdate) (*models.Category, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Параллельные переносы могли бы вместе образовать цикл, который не видит
+	// ни одна из проверок, поэтому изменения дерева выполняются по очереди
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE categories IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return nil, err
+	}
+
+	if update.ParentID != nil {
+		var exists bool
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM categories WHERE id = $1)", *update.ParentID).Scan(&exists)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, fmt.Errorf("parent category with id %d does not exist", *update.ParentID)
+		}
+
+		var cycle bool
+		err = tx.QueryRowContext(ctx, "SELECT $2 IN ("+fmt.Sprintf(categorySubtree, 1)+")", id, *update.ParentID).Scan(&cycle)
+		if err != nil {
+			return nil, err
+		}
+		if cycle {
+			return nil, fmt.Errorf("category cannot be moved under itself or its descendant")
+		}
+	}
+
+	category := models.Category{ID: id, Name: update.Name, Attributes: update.Attributes, ParentID: update.ParentID}
+	res, err := tx.ExecContext(ctx, `
+		UPDATE categories SET name = $2, attributes = $3, parent_id = $4
+		WHERE id = $1
+	`, id, update.Name, update.Attributes, update.ParentID)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("category with name %s already exists", update.Name)
+		}
+		return nil, err
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if updated == 0 {
+		return nil, fmt.Errorf("category with id %d does not exist", id)
+	}
+
+	// Проверка идет после UPDATE: блокировка строки категории дождалась
+	// объявлений, которые создавались по старой схеме
+	if err := checkAdAttributes(ctx, tx, id, update.Attributes); err != nil {
+		return nil, err
+	}
+
+	if category.Breadcrumbs, err = categoryBreadcrumbs(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// Delete удаляет категорию без подкатегорий. Объявления категории, включая
+// удаленные, при reassignTo > 0 переносятся в категорию reassignTo, если
+// подходят под ее схему атрибутов (иначе CategoryAdsInvalidError); без
+// reassignTo удаление запрещено.
+func (r *CategoryRepository) Delete(ctx context.Context, id, reassignTo int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var locked int
+	err = tx.QueryRowContext(ctx, "SELECT id FROM categories WHERE id = $1 FOR UPDATE", id).Scan(&locked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("category with id %d does not exist", id)
+		}
+		return err
+	}
+
+	var hasChildren bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM categories WHERE parent_id = $1)", id).Scan(&hasChildren); err != nil {
+		return err
+	}
+	if hasChildren {
+		return fmt.Errorf("category with id %d has subcategories", id)
+	}
+
+	if reassignTo > 0 {
+		// Блокировка не дает удалить целевую категорию или изменить ее схему
+		// атрибутов, пока в нее переносятся объявления
+		var schema models.AttributeSchema
+		err := tx.QueryRowContext(ctx, "SELECT attributes FROM categories WHERE id = $1 FOR SHARE", reassignTo).Scan(&schema)
+		if err == sql.ErrNoRows || reassignTo == id {
+			return fmt.Errorf("reassign target category with id %d does not exist", reassignTo)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Объявления должны подходить под схему целевой категории
+		if err := checkAdAttributes(ctx, tx, id, schema); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE ads SET category_id = $2 WHERE category_id = $1", id, reassignTo); err != nil {
+			return err
+		}
+	} else {
+		var ads int
+		if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM ads WHERE category_id = $1", id).Scan(&ads); err != nil {
+			return err
+		}
+		if ads > 0 {
+			return &CategoryHasAdsError{Ads: ads}
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM categories WHERE id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Seed добавляет категории, которых еще нет; существующие с тем же
+// названием не меняются. Возвращает количество добавленных категорий.
+func (r *CategoryRepository) Seed(ctx context.Context, categories []models.CategoryCreate) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	added := 0
+	for _, category := range categories {
+		res, err := tx.ExecContext(ctx, `
+			INSERT INTO categories (name, attributes) VALUES ($1, $2)
+			ON CONFLICT (name) DO NOTHING
+		`, category.Name, category.Attributes)
+		if err != nil {
+			return 0, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		added += int(n)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return added, nil
+}
@@ -0,0 +1,369 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang-test/internal/auth"
+	"golang-test/internal/imagegc"
+	"golang-test/internal/imaging"
+	"golang-test/internal/models"
+	"golang-test/internal/purge"
+	"golang-test/internal/repository"
+	"golang-test/internal/storage"
+	"golang-test/internal/upload"
+
+	"github.com/gin-gonic/gin/binding"
+)
+
+// commandDeps - зависимости, доступные служебным командам
+type commandDeps struct {
+	db      *sql.DB
+	store   storage.Storage
+	uploads *upload.Service
+	gc      imagegc.Config
+	purge   purge.Config
+}
+
+// command - служебная команда, запускаемая как `server <команда> [аргументы]`
+type command struct {
+	description string
+	run         func(ctx context.Context, deps *commandDeps, args []string) error
+}
+
+var commands = map[string]command{
+	"create-api-key": {
+		description: "выпустить API-ключ: create-api-key <email> <название> <области через запятую> [срок, например 720h]",
+		run:         createAPIKeyCommand,
+	},
+	"generate-variants": {
+		description: "создать недостающие уменьшенные копии (JPEG и, в сборке с тегом webp, WebP) для уже загруженных изображений",
+		run:         generateVariantsCommand,
+	},
+	"gc-images": {
+		description: "удалить файлы изображений без ссылок и найти ссылки на отсутствующие файлы (-dry-run, -grace)",
+		run:         gcImagesCommand,
+	},
+	"grant-role": {
+		description: "добавить роль пользователю: grant-role <email> <user|moderator|admin>",
+		run:         grantRoleCommand,
+	},
+	"purge-deleted": {
+		description: "окончательно удалить пользователей и объявления, удаленные раньше срока хранения (-retention)",
+		run:         purgeDeletedCommand,
+	},
+	"revoke-api-key": {
+		description: "отозвать API-ключ: revoke-api-key <id>",
+		run:         revokeAPIKeyCommand,
+	},
+	"seed-categories": {
+		description: "добавить недостающие категории из JSON-файла: seed-categories <файл>",
+		run:         seedCategoriesCommand,
+	},
+	"strip-metadata": {
+		description: "удалить EXIF/XMP-метаданные из уже загруженных изображений",
+		run:         stripMetadataCommand,
+	},
+}
+
+func runCommand(ctx context.Context, deps *commandDeps, name string, args []string) error {
+	cmd, ok := commands[name]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		fmt.Fprintln(os.Stderr, "Доступные команды:")
+		for _, n := range names {
+			fmt.Fprintf(os.Stderr, "  %-20s %s\n", n, commands[n].description)
+		}
+		return fmt.Errorf("unknown command %q", name)
+	}
+	return cmd.run(ctx, deps, args)
+}
+
+// originals возвращает ключи оригиналов изображений в хранилище
+func originals(ctx context.Context, store storage.Storage) ([]string, error) {
+	var keys []string
+	err := store.List(ctx, func(obj storage.Object) error {
+		if !imaging.IsVariant(obj.Key) {
+			keys = append(keys, obj.Key)
+		}
+		return nil
+	})
+	return keys, err
+}
+
+// readObject читает объект хранилища целиком
+func readObject(ctx context.Context, store storage.Storage, key string) ([]byte, error) {
+	r, _, err := store.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
+
+func generateVariantsCommand(ctx context.Context, deps *commandDeps, args []string) error {
+	keys, err := originals(ctx, deps.store)
+	if err != nil {
+		return err
+	}
+
+	var generated, failed int
+	for _, key := range keys {
+		missing := false
+		for _, name := range imaging.VariantFilenames(key) {
+			if _, err := deps.store.Stat(ctx, name); errors.Is(err, storage.ErrNotFound) {
+				missing = true
+				break
+			}
+		}
+		if !missing {
+			continue
+		}
+
+		data, err := readObject(ctx, deps.store, key)
+		if err != nil {
+			return err
+		}
+
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err == nil {
+			err = deps.uploads.WriteVariants(ctx, key, img)
+		}
+		if err != nil {
+			slog.Error("failed to generate image variants", "error", err, "filename", key)
+			failed++
+			continue
+		}
+		generated++
+	}
+
+	slog.Info("image variants generated", "generated", generated, "failed", failed)
+	return nil
+}
+
+// stripMetadataCommand перезаписывает оригиналы, содержащие метаданные, и заново
+// создает их варианты, так как после применения ориентации изображение могло повернуться
+func stripMetadataCommand(ctx context.Context, deps *commandDeps, args []string) error {
+	keys, err := originals(ctx, deps.store)
+	if err != nil {
+		return err
+	}
+
+	var cleaned, failed int
+	for _, key := range keys {
+		data, err := readObject(ctx, deps.store, key)
+		if err != nil {
+			return err
+		}
+		if !imaging.HasMetadata(data) {
+			continue
+		}
+
+		clean, img, err := imaging.Sanitize(data)
+		if err != nil {
+			slog.Error("failed to strip image metadata", "error", err, "filename", key)
+			failed++
+			continue
+		}
+
+		if err := deps.store.Put(ctx, key, clean, http.DetectContentType(clean)); err != nil {
+			return err
+		}
+
+		if err := deps.uploads.WriteVariants(ctx, key, img); err != nil {
+			slog.Error("failed to regenerate image variants", "error", err, "filename", key)
+			failed++
+			continue
+		}
+		cleaned++
+	}
+
+	slog.Info("image metadata stripped", "cleaned", cleaned, "failed", failed)
+	return nil
+}
+
+// gcImagesCommand выполняет один проход сборщика и печатает отчет в stdout
+func gcImagesCommand(ctx context.Context, deps *commandDeps, args []string) error {
+	fs := flag.NewFlagSet("gc-images", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", deps.gc.DryRun, "только показать отчет, ничего не удалять")
+	grace := fs.Duration("grace", deps.gc.Grace, "не удалять файлы моложе указанного возраста")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := imagegc.NewCollector(deps.db, deps.store, *grace).Run(ctx, *dryRun)
+	if err != nil {
+		return err
+	}
+	report.Log()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// purgeDeletedCommand выполняет один проход очистки и печатает отчет в stdout
+func purgeDeletedCommand(ctx context.Context, deps *commandDeps, args []string) error {
+	fs := flag.NewFlagSet("purge-deleted", flag.ContinueOnError)
+	retention := fs.Duration("retention", deps.purge.Retention, "удалять записи, удаленные раньше указанного срока")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	purger := purge.NewPurger(
+		repository.NewAdRepository(deps.db, deps.store),
+		repository.NewUserRepository(deps.db),
+		*retention,
+	)
+	report, err := purger.Run(ctx)
+	if err != nil {
+		return err
+	}
+	report.Log()
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(report)
+}
+
+// seedCategoriesCommand добавляет категории из JSON-массива вида
+// [{"name": "Clothing", "attributes": [{"name": "size", "type": "enum", "enum": ["S", "M", "L"]}]}];
+// категории, которые уже есть, не меняются
+func seedCategoriesCommand(ctx context.Context, deps *commandDeps, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: seed-categories <file>")
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	var categories []models.CategoryCreate
+	if err := json.Unmarshal(data, &categories); err != nil {
+		return fmt.Errorf("invalid categories file: %w", err)
+	}
+	for i := range categories {
+		if err := binding.Validator.ValidateStruct(&categories[i]); err != nil {
+			return fmt.Errorf("invalid category #%d: %w", i+1, err)
+		}
+		if err := categories[i].Attributes.Check(); err != nil {
+			return fmt.Errorf("invalid category #%d: %w", i+1, err)
+		}
+	}
+
+	added, err := repository.NewCategoryRepository(deps.db).Seed(ctx, categories)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("categories seeded", "added", added, "skipped", len(categories)-added)
+	return nil
+}
+
+// grantRoleCommand добавляет роль пользователю; так назначается первый администратор
+func grantRoleCommand(ctx context.Context, deps *commandDeps, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: grant-role <email> <role>")
+	}
+	email, role := args[0], args[1]
+
+	user, _, err := repository.NewUserRepository(deps.db).GetCredentials(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user with email %s does not exist", email)
+	}
+
+	roles := user.Roles
+	if !slices.Contains(roles, role) {
+		roles = append(roles, role)
+	}
+	if err := repository.NewRoleRepository(deps.db).SetUserRoles(ctx, user.ID, roles); err != nil {
+		return err
+	}
+
+	slog.Info("user role granted", "email", email, "role", role, "roles", roles)
+	return nil
+}
+
+// createAPIKeyCommand выпускает ключ и печатает его в stdout; больше ключ нигде не показывается
+func createAPIKeyCommand(ctx context.Context, deps *commandDeps, args []string) error {
+	if len(args) != 3 && len(args) != 4 {
+		return errors.New("usage: create-api-key <email> <name> <scopes> [ttl]")
+	}
+	email := args[0]
+
+	create := models.APIKeyCreate{Name: args[1], Scopes: strings.Split(args[2], ",")}
+	for _, scope := range create.Scopes {
+		if scope != models.ScopeReadOnly && scope != models.ScopeAdsWrite && scope != models.ScopeAdmin {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	if len(args) == 4 {
+		ttl, err := time.ParseDuration(args[3])
+		if err != nil || ttl <= 0 {
+			return fmt.Errorf("invalid ttl %q", args[3])
+		}
+		expiresAt := time.Now().Add(ttl)
+		create.ExpiresAt = &expiresAt
+	}
+
+	user, _, err := repository.NewUserRepository(deps.db).GetCredentials(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user with email %s does not exist", email)
+	}
+
+	key, prefix, hash, err := auth.NewAPIKey()
+	if err != nil {
+		return err
+	}
+	apiKey, err := repository.NewAPIKeyRepository(deps.db).Create(ctx, user.ID, &create, prefix, hash)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("api key created", "api_key_id", apiKey.ID, "user_id", user.ID, "scopes", apiKey.Scopes)
+	fmt.Println(key)
+	return nil
+}
+
+func revokeAPIKeyCommand(ctx context.Context, deps *commandDeps, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: revoke-api-key <id>")
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("invalid api key id %q", args[0])
+	}
+
+	if err := repository.NewAPIKeyRepository(deps.db).Revoke(ctx, id); err != nil {
+		return err
+	}
+
+	slog.Info("api key revoked", "api_key_id", id)
+	return nil
+}
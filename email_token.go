@@ -0,0 +1,18 @@
+package models
+
+// Назначения одноразовых токенов из писем
+const (
+	TokenEmailVerification = "email_verification"
+	TokenPasswordReset     = "password_reset"
+)
+
+// PasswordResetRequest - запрос письма для сброса пароля
+type PasswordResetRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// PasswordResetConfirm - новый пароль и токен из письма
+type PasswordResetConfirm struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=8,max=72"`
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang-test/internal/models"
+)
+
+// EmailTokenRepository хранит одноразовые токены подтверждения email
+// и сброса пароля
+type EmailTokenRepository struct {
+	db *sql.DB
+}
+
+func NewEmailTokenRepository(db *sql.DB) *EmailTokenRepository {
+	return &EmailTokenRepository{db: db}
+}
+
+// ErrEmailTokenTooSoon возвращается Create, если токен того же назначения
+// уже выдавался на этот адрес в течение интервала
+var ErrEmailTokenTooSoon = errors.New("email token was issued recently")
+
+// Create сохраняет токен для текущего email пользователя. Ранее выданные
+// неиспользованные токены того же назначения перестают действовать.
+// Если на тот же адрес токен этого назначения выдавался позже чем interval
+// назад, возвращается ErrEmailTokenTooSoon: так запросы писем нельзя
+// использовать для рассылки на чужой адрес.
+func (r *EmailTokenRepository) Create(ctx context.Context, user *models.User, purpose, hash string, expiresAt time.Time, interval time.Duration) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Блокировка пользователя, чтобы параллельные запросы не прошли проверку оба
+	var locked int
+	err = tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = $1 FOR UPDATE", user.ID).Scan(&locked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user with id %d does not exist", user.ID)
+		}
+		return err
+	}
+
+	var recent bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS(
+			SELECT 1 FROM email_tokens
+			WHERE user_id = $1 AND purpose = $2 AND email = $3
+			  AND created_at > NOW() - $4 * INTERVAL '1 second'
+		)
+	`, user.ID, purpose, user.Email, interval.Seconds()).Scan(&recent)
+	if err != nil {
+		return err
+	}
+	if recent {
+		return ErrEmailTokenTooSoon
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		DELETE FROM email_tokens
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL
+	`, user.ID, purpose)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO email_tokens (user_id, purpose, email, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+	`, user.ID, purpose, user.Email, hash, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// consume погашает токен и возвращает ID пользователя. Токен недействителен,
+// если он уже использован, истек или email пользователя с тех пор изменился.
+func (r *EmailTokenRepository) consume(ctx context.Context, tx *sql.Tx, purpose, hash string) (int, error) {
+	var tokenID, userID int
+	var expiresAt time.Time
+	var usedAt sql.NullTime
+	var tokenEmail, userEmail string
+	err := tx.QueryRowContext(ctx, `
+		SELECT t.id, t.user_id, t.expires_at, t.used_at, t.email, u.email
+		FROM email_tokens t
+		JOIN users u ON u.id = t.user_id
+		WHERE t.token_hash = $1 AND t.purpose = $2 AND u.deleted_at IS NULL
+		FOR UPDATE OF t, u
+	`, hash, purpose).Scan(&tokenID, &userID, &expiresAt, &usedAt, &tokenEmail, &userEmail)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, fmt.Errorf("invalid or expired token")
+		}
+		return 0, err
+	}
+
+	if usedAt.Valid || time.Now().After(expiresAt) || tokenEmail != userEmail {
+		return 0, fmt.Errorf("invalid or expired token")
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE email_tokens SET used_at = NOW() WHERE id = $1", tokenID); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// VerifyEmail погашает токен подтверждения и отмечает email пользователя подтвержденным
+func (r *EmailTokenRepository) VerifyEmail(ctx context.Context, hash string) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	userID, err := r.consume(ctx, tx, models.TokenEmailVerification, hash)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users SET email_verified_at = COALESCE(email_verified_at, NOW())
+		WHERE id = $1
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
+
+// ResetPassword погашает токен сброса, меняет пароль и отзывает все сессии
+// пользователя. Письмо дошло до владельца адреса, поэтому email тоже
+// считается подтвержденным.
+func (r *EmailTokenRepository) ResetPassword(ctx context.Context, hash, passwordHash string) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	userID, err := r.consume(ctx, tx, models.TokenPasswordReset, hash)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users
+		SET password_hash = $2, email_verified_at = COALESCE(email_verified_at, NOW())
+		WHERE id = $1
+	`, userID, passwordHash)
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE auth_sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, tx.Commit()
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"golang-test/internal/imageurl"
+	"golang-test/internal/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImageHandler struct {
+	store storage.Storage
+	urls  *imageurl.Builder
+}
+
+func NewImageHandler(store storage.Storage, urls *imageurl.Builder) *ImageHandler {
+	return &ImageHandler{store: store, urls: urls}
+}
+
+// ServeImage отдает файл изображения
+// @Summary Получить изображение
+// @Description Отдает файл изображения с заголовками кэширования (ETag, Last-Modified) и поддержкой Range. Если на сервере задан IMAGE_URL_SECRET, требуются параметры expires и sig из ссылки в объявлении
+// @Tags images
+// @Produce image/jpeg
+// @Produce image/png
+// @Param filename path string true "Имя файла"
+// @Param expires query int false "Срок действия ссылки (unix time)"
+// @Param sig query string false "Подпись ссылки"
+// @Success 200 {file} file
+// @Success 206 {file} file
+// @Success 304
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /images/{filename} [get]
+func (h *ImageHandler) ServeImage(c *gin.Context) {
+	filename := c.Param("filename")
+
+	cacheControl := "public, max-age=31536000, immutable"
+	if h.urls.Signed() {
+		expires := c.Query("expires")
+		if err := h.urls.Verify(filename, expires, c.Query("sig")); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		// Кэшировать ответ можно не дольше, чем действует ссылка
+		exp, _ := strconv.ParseInt(expires, 10, 64)
+		maxAge := exp - time.Now().Unix()
+		cacheControl = fmt.Sprintf("private, max-age=%d", maxAge)
+	}
+
+	file, obj, err := h.store.Get(c.Request.Context(), filename)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "image not found",
+			})
+			return
+		}
+		slog.Error("failed to get image", "error", err, "filename", filename)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+	defer file.Close()
+
+	contentType := obj.ContentType
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = mime.TypeByExtension(filepath.Ext(filename))
+	}
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+
+	// Имена файлов уникальны, но содержимое может быть перезаписано
+	// (например, командой strip-metadata), поэтому ETag учитывает
+	// время изменения и размер
+	c.Header("ETag", fmt.Sprintf(`"%x-%x"`, obj.ModTime.UnixNano(), obj.Size))
+	c.Header("Cache-Control", cacheControl)
+
+	// ServeContent обрабатывает If-None-Match, If-Modified-Since и Range
+	http.ServeContent(c.Writer, c.Request, filename, obj.ModTime, file)
+}
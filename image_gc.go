@@ -0,0 +1,323 @@
+package imagegc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"golang-test/internal/imaging"
+	"golang-test/internal/storage"
+)
+
+// Config - настройки фоновой сборки мусора
+type Config struct {
+	// Interval - период запуска; 0 отключает фоновую сборку
+	Interval time.Duration
+	// Grace - файлы моложе этого возраста не удаляются: они могут
+	// принадлежать загрузке, которая еще не записана в базу
+	Grace  time.Duration
+	DryRun bool
+}
+
+// ConfigFromEnv читает настройки из переменных окружения:
+//
+//	IMAGE_GC_INTERVAL  период фоновой сборки, по умолчанию 24h; 0 отключает ее
+//	IMAGE_GC_GRACE     минимальный возраст удаляемого файла, по умолчанию 24h
+//	IMAGE_GC_DRY_RUN   true - только отчет, без удаления
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Interval: 24 * time.Hour, Grace: 24 * time.Hour}
+
+	if v := os.Getenv("IMAGE_GC_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, errors.New("invalid IMAGE_GC_INTERVAL")
+		}
+		cfg.Interval = d
+	}
+	if v := os.Getenv("IMAGE_GC_GRACE"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, errors.New("invalid IMAGE_GC_GRACE")
+		}
+		cfg.Grace = d
+	}
+	if v := os.Getenv("IMAGE_GC_DRY_RUN"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return cfg, errors.New("invalid IMAGE_GC_DRY_RUN")
+		}
+		cfg.DryRun = b
+	}
+	return cfg, nil
+}
+
+// MissingFile - запись в базе, файл которой отсутствует в хранилище.
+// ImageID равен 0 для ссылки из ads.image_filename без строки в ad_images.
+type MissingFile struct {
+	AdID     int    `json:"ad_id"`
+	ImageID  int    `json:"image_id"`
+	Filename string `json:"filename"`
+}
+
+// Report - результат одного прохода сборщика
+type Report struct {
+	DryRun       bool          `json:"dry_run"`
+	OrphanFiles  []string      `json:"orphan_files"`
+	MissingFiles []MissingFile `json:"missing_files"`
+	// TempFiles - временные файлы старше grace-периода, оставшиеся после
+	// сбоя записи в хранилище (см. storage.TempStorage)
+	TempFiles []string `json:"temp_files"`
+	// Skipped - файлы без ссылок, которые моложе grace-периода
+	// или получили ссылку во время сборки
+	Skipped int `json:"skipped"`
+	Deleted int `json:"deleted"`
+	Failed  int `json:"failed"`
+}
+
+// Collector сверяет файлы хранилища с записями об изображениях
+type Collector struct {
+	db    *sql.DB
+	store storage.Storage
+	grace time.Duration
+}
+
+func NewCollector(db *sql.DB, store storage.Storage, grace time.Duration) *Collector {
+	return &Collector{db: db, store: store, grace: grace}
+}
+
+// Run находит файлы без ссылок и ссылки на отсутствующие файлы.
+// Без dryRun файлы без ссылок старше grace-периода удаляются.
+func (c *Collector) Run(ctx context.Context, dryRun bool) (*Report, error) {
+	cutoff := time.Now().Add(-c.grace)
+
+	objects := make(map[string]storage.Object)
+	err := c.store.List(ctx, func(obj storage.Object) error {
+		objects[obj.Key] = obj
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Ссылки читаются после списка файлов. Файл, загруженный и привязанный
+	// к объявлению между этими шагами, либо окажется среди ссылок, либо
+	// будет моложе grace-периода - в обоих случаях он не удаляется.
+	refs, err := c.references(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		DryRun:       dryRun,
+		OrphanFiles:  []string{},
+		MissingFiles: []MissingFile{},
+		TempFiles:    []string{},
+	}
+
+	referenced := make(map[string]bool)
+	for _, ref := range refs {
+		for _, name := range imaging.Filenames(ref.Filename) {
+			referenced[name] = true
+			if _, ok := objects[name]; !ok {
+				report.MissingFiles = append(report.MissingFiles, MissingFile{
+					AdID:     ref.AdID,
+					ImageID:  ref.ImageID,
+					Filename: name,
+				})
+			}
+		}
+	}
+
+	// При повторной загрузке того же изображения перезаписывается только
+	// оригинал, поэтому возраст варианта определяется и по его оригиналу
+	modTimes := make(map[string]time.Time, len(objects))
+	originals := make(map[string]string, len(objects))
+	for key, obj := range objects {
+		if obj.ModTime.After(modTimes[key]) {
+			modTimes[key] = obj.ModTime
+		}
+		if imaging.IsVariant(key) {
+			continue
+		}
+		for _, name := range imaging.VariantFilenames(key) {
+			originals[name] = key
+			if obj.ModTime.After(modTimes[name]) {
+				modTimes[name] = obj.ModTime
+			}
+		}
+	}
+
+	for key := range objects {
+		if referenced[key] {
+			continue
+		}
+		if modTimes[key].After(cutoff) {
+			report.Skipped++
+			continue
+		}
+		report.OrphanFiles = append(report.OrphanFiles, key)
+	}
+	sort.Strings(report.OrphanFiles)
+
+	temp, isTemp := c.store.(storage.TempStorage)
+	if isTemp {
+		// Временный файл моложе grace-периода может принадлежать текущей записи
+		err := temp.ListTemp(ctx, func(obj storage.Object) error {
+			if obj.ModTime.Before(cutoff) {
+				report.TempFiles = append(report.TempFiles, obj.Key)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(report.TempFiles)
+	}
+
+	if dryRun {
+		return report, nil
+	}
+
+	for _, key := range report.OrphanFiles {
+		// Ссылки хранят имя оригинала, и блокируется тоже оригинал.
+		// Вариант без оригинала сослаться уже не может, его ключ блокируется сам.
+		original, ok := originals[key]
+		if !ok {
+			original = key
+		}
+		deleted, err := c.deleteOrphan(ctx, key, original)
+		if err != nil {
+			slog.Error("failed to delete orphaned image", "error", err, "filename", key)
+			report.Failed++
+			continue
+		}
+		if !deleted {
+			report.Skipped++
+			continue
+		}
+		report.Deleted++
+	}
+
+	// На временные файлы ссылок не бывает, блокировка не нужна
+	for _, key := range report.TempFiles {
+		if err := temp.DeleteTemp(ctx, key); err != nil {
+			slog.Error("failed to delete temp file", "error", err, "filename", key)
+			report.Failed++
+			continue
+		}
+		report.Deleted++
+	}
+
+	return report, nil
+}
+
+// deleteOrphan удаляет файл key, удерживая блокировку оригинала original,
+// как это делают объявления при добавлении и удалении изображений.
+// Пока блокировка удерживается, сослаться на файл нельзя, а ссылку, созданную
+// после чтения ссылок в Run, видно здесь, и тогда файл сохраняется.
+// Возвращает false, если на файл успели сослаться.
+func (c *Collector) deleteOrphan(ctx context.Context, key, original string) (bool, error) {
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", original); err != nil {
+		return false, err
+	}
+
+	var referenced bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS(SELECT 1 FROM image_files WHERE filename = $1)
+			OR EXISTS(SELECT 1 FROM ad_images WHERE filename = $1)
+			OR EXISTS(SELECT 1 FROM ads WHERE image_filename = $1)`, original).Scan(&referenced)
+	if err != nil {
+		return false, err
+	}
+	if referenced {
+		return false, nil
+	}
+
+	if err := c.store.Delete(ctx, key); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// references возвращает все имена файлов, на которые ссылаются объявления
+func (c *Collector) references(ctx context.Context) ([]MissingFile, error) {
+	query := `
+		SELECT ad_id, id, filename FROM ad_images
+		UNION ALL
+		SELECT a.id, 0, a.image_filename FROM ads a
+		WHERE a.image_filename <> ''
+		  AND NOT EXISTS (
+			SELECT 1 FROM ad_images ai
+			WHERE ai.ad_id = a.id AND ai.filename = a.image_filename
+		  )
+		ORDER BY 1, 2`
+
+	rows, err := c.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var refs []MissingFile
+	for rows.Next() {
+		var ref MissingFile
+		if err := rows.Scan(&ref.AdID, &ref.ImageID, &ref.Filename); err != nil {
+			return nil, err
+		}
+		refs = append(refs, ref)
+	}
+	return refs, rows.Err()
+}
+
+// Start запускает периодическую сборку до отмены контекста
+func (c *Collector) Start(ctx context.Context, interval time.Duration, dryRun bool) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			report, err := c.Run(ctx, dryRun)
+			if err != nil {
+				slog.Error("image garbage collection failed", "error", err)
+				continue
+			}
+			report.Log()
+		}
+	}()
+}
+
+// Log пишет отчет в журнал
+func (r *Report) Log() {
+	for _, m := range r.MissingFiles {
+		slog.Warn("image file is missing", "ad_id", m.AdID, "image_id", m.ImageID, "filename", m.Filename)
+	}
+	slog.Info("image garbage collection finished",
+		"dry_run", r.DryRun,
+		"orphans", len(r.OrphanFiles),
+		"missing", len(r.MissingFiles),
+		"temp", len(r.TempFiles),
+		"skipped", r.Skipped,
+		"deleted", r.Deleted,
+		"failed", r.Failed)
+}
@@ -0,0 +1,143 @@
+package imageurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang-test/internal/imaging"
+	"golang-test/internal/models"
+)
+
+var (
+	ErrExpired          = errors.New("signed url expired")
+	ErrInvalidSignature = errors.New("invalid url signature")
+)
+
+// Builder строит абсолютные ссылки на изображения и, если задан секрет,
+// подписывает их HMAC с ограниченным сроком действия
+type Builder struct {
+	baseURL string
+	secret  []byte
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+func NewBuilder(baseURL string, secret []byte, ttl time.Duration) *Builder {
+	return &Builder{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		secret:  secret,
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// FromEnv настраивает построитель ссылок по переменным окружения:
+//
+//	PUBLIC_BASE_URL   внешний адрес сервера, по умолчанию http://localhost:8080
+//	IMAGE_URL_SECRET  секрет для подписи ссылок; если пуст, ссылки не подписываются
+//	IMAGE_URL_TTL     срок действия подписанной ссылки, по умолчанию 1h;
+//	                  не меньше секунды, так как срок в ссылке задается в секундах
+func FromEnv() (*Builder, error) {
+	baseURL := os.Getenv("PUBLIC_BASE_URL")
+	if baseURL == "" {
+		baseURL = "http://localhost:8080"
+	}
+
+	ttl := time.Hour
+	if v := os.Getenv("IMAGE_URL_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < time.Second {
+			return nil, errors.New("invalid IMAGE_URL_TTL: must be at least 1s")
+		}
+		ttl = d
+	}
+
+	return NewBuilder(baseURL, []byte(os.Getenv("IMAGE_URL_SECRET")), ttl), nil
+}
+
+// BaseURL возвращает внешний адрес сервера без завершающего слеша
+func (b *Builder) BaseURL() string {
+	return b.baseURL
+}
+
+// Signed сообщает, требуется ли подпись для доступа к изображениям
+func (b *Builder) Signed() bool {
+	return len(b.secret) > 0
+}
+
+// URL возвращает абсолютную ссылку на файл изображения
+func (b *Builder) URL(filename string) string {
+	u := b.baseURL + "/images/" + url.PathEscape(filename)
+	if !b.Signed() {
+		return u
+	}
+
+	// Срок округляется до границы окна ttl: ссылка на один и тот же файл
+	// не меняется в течение окна, поэтому ее можно кэшировать на клиенте.
+	// Оставшееся время действия - от ttl до 2*ttl.
+	window := int64(b.ttl / time.Second)
+	expires := (b.now().Unix()/window + 2) * window
+	exp := strconv.FormatInt(expires, 10)
+
+	return u + "?expires=" + exp + "&sig=" + b.sign(filename, exp)
+}
+
+// Verify проверяет подпись и срок действия ссылки
+func (b *Builder) Verify(filename, expires, sig string) error {
+	exp, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if !hmac.Equal([]byte(sig), []byte(b.sign(filename, expires))) {
+		return ErrInvalidSignature
+	}
+	if b.now().Unix() > exp {
+		return ErrExpired
+	}
+	return nil
+}
+
+func (b *Builder) sign(filename, expires string) string {
+	mac := hmac.New(sha256.New, b.secret)
+	mac.Write([]byte(filename + "\n" + expires))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Ad заменяет имена файлов в объявлении абсолютными ссылками
+func (b *Builder) Ad(ad *models.Ad) {
+	if ad.Image != "" {
+		ad.Image = b.URL(ad.Image)
+	}
+	b.Images(ad.Images)
+}
+
+// Images заполняет ссылки на изображения и их варианты
+func (b *Builder) Images(images []models.AdImage) {
+	for i := range images {
+		img := &images[i]
+		img.URL = b.URL(img.Filename)
+		img.Variants = models.ImageVariants{
+			VariantURLs: b.variantURLs(img.Filename, imaging.JPEG),
+		}
+		if imaging.WebP != nil {
+			webp := b.variantURLs(img.Filename, *imaging.WebP)
+			img.Variants.WebP = &webp
+		}
+	}
+}
+
+// variantURLs возвращает ссылки на варианты изображения в формате f
+func (b *Builder) variantURLs(filename string, f imaging.Format) models.VariantURLs {
+	return models.VariantURLs{
+		Thumb:  b.URL(imaging.VariantFilename(filename, "thumb", f)),
+		Medium: b.URL(imaging.VariantFilename(filename, "medium", f)),
+		Large:  b.URL(imaging.VariantFilename(filename, "large", f)),
+	}
+}
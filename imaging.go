@@ -0,0 +1,117 @@
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	_ "image/png"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/draw"
+)
+
+// Variant - уменьшенная копия изображения, вписанная в квадрат MaxSize x MaxSize
+type Variant struct {
+	Name    string
+	MaxSize int
+}
+
+// Variants - размеры, которые генерируются для каждого загруженного изображения
+var Variants = []Variant{
+	{Name: "thumb", MaxSize: 200},
+	{Name: "medium", MaxSize: 800},
+	{Name: "large", MaxSize: 1600},
+}
+
+// Format - формат, в котором сохраняются варианты
+type Format struct {
+	Ext         string
+	ContentType string
+	encode      func(w io.Writer, img image.Image) error
+}
+
+const jpegQuality = 85
+
+// JPEG - основной формат вариантов, создается всегда
+var JPEG = Format{Ext: ".jpg", ContentType: "image/jpeg", encode: func(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+}}
+
+// Formats - все форматы вариантов: JPEG и, в сборке с тегом webp, WebP
+var Formats = formats()
+
+func formats() []Format {
+	if WebP == nil {
+		return []Format{JPEG}
+	}
+	return []Format{JPEG, *WebP}
+}
+
+// ErrInvalidImage возвращается, если файл не удалось декодировать как изображение
+var ErrInvalidImage = errors.New("invalid image")
+
+// VariantFilename возвращает имя файла варианта: <имя без расширения>_<вариант><расширение формата>
+func VariantFilename(filename, variant string, format Format) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + "_" + variant + format.Ext
+}
+
+// VariantFilenames возвращает имена файлов всех вариантов изображения во всех форматах
+func VariantFilenames(filename string) []string {
+	names := make([]string, 0, len(Variants)*len(Formats))
+	for _, v := range Variants {
+		for _, f := range Formats {
+			names = append(names, VariantFilename(filename, v.Name, f))
+		}
+	}
+	return names
+}
+
+// IsVariant сообщает, является ли файл вариантом, а не оригиналом
+func IsVariant(filename string) bool {
+	for _, v := range Variants {
+		for _, f := range Formats {
+			if strings.HasSuffix(filename, "_"+v.Name+f.Ext) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Filenames возвращает имя оригинала и имена всех его вариантов
+func Filenames(filename string) []string {
+	return append([]string{filename}, VariantFilenames(filename)...)
+}
+
+// RenderVariant уменьшает изображение до размеров варианта и кодирует его в формате f
+func RenderVariant(src image.Image, v Variant, f Format) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := f.encode(&buf, resize(src, v.MaxSize)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resize вписывает изображение в квадрат maxSize, не увеличивая его.
+// Прозрачные области заливаются белым, так как JPEG не поддерживает альфа-канал;
+// WebP получает ту же картинку, чтобы форматы не отличались.
+func resize(src image.Image, maxSize int) image.Image {
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > maxSize || h > maxSize {
+		if w >= h {
+			w, h = maxSize, max(1, h*maxSize/w)
+		} else {
+			w, h = max(1, w*maxSize/h), maxSize
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+	return dst
+}
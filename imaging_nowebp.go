@@ -0,0 +1,7 @@
+//go:build !webp
+
+package imaging
+
+// WebP равен nil: без тега сборки webp варианты создаются только в JPEG,
+// и сборка не требует cgo (см. imaging_webp.go)
+var WebP *Format
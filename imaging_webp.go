@@ -0,0 +1,19 @@
+//go:build webp
+
+package imaging
+
+import (
+	"image"
+	"io"
+
+	"github.com/chai2010/webp"
+)
+
+const webpQuality = 80
+
+// WebP - дополнительный формат вариантов: файлы заметно меньше JPEG.
+// Кодировщик использует libwebp через cgo, поэтому формат включается
+// только в сборке с тегом webp: go build -tags webp
+var WebP = &Format{Ext: ".webp", ContentType: "image/webp", encode: func(w io.Writer, img image.Image) error {
+	return webp.Encode(w, img, &webp.Options{Quality: webpQuality})
+}}
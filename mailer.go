@@ -0,0 +1,114 @@
+package mailer
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Message - текстовое письмо одному получателю
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer отправляет письма
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FromEnv создает отправителя по переменным окружения:
+//
+//	MAIL_BACKEND   log (по умолчанию) - письма пишутся в журнал,
+//	               file - в каталог MAIL_DIR, smtp - на SMTP-сервер
+//	MAIL_FROM      адрес отправителя, по умолчанию no-reply@localhost
+//	MAIL_DIR       каталог для файлового режима, по умолчанию mail
+//	SMTP_HOST      адрес SMTP-сервера
+//	SMTP_PORT      порт SMTP-сервера, по умолчанию 25 (у MailHog - 1025)
+//	SMTP_USERNAME  логин, если сервер требует авторизации
+//	SMTP_PASSWORD  пароль
+func FromEnv() (Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "no-reply@localhost"
+	}
+
+	switch backend := os.Getenv("MAIL_BACKEND"); backend {
+	case "", "log":
+		return NewLog(from), nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "mail"
+		}
+		return NewFile(dir, from)
+	case "smtp":
+		port := 25
+		if v := os.Getenv("SMTP_PORT"); v != "" {
+			p, err := strconv.Atoi(v)
+			if err != nil || p <= 0 {
+				return nil, errors.New("invalid SMTP_PORT")
+			}
+			port = p
+		}
+		return NewSMTP(SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		})
+	default:
+		return nil, fmt.Errorf("unknown mail backend %q", backend)
+	}
+}
+
+// build собирает письмо в формате RFC 5322. Тема кодируется по RFC 2047,
+// тело - quoted-printable, поэтому кириллица передается без искажений.
+func build(from string, msg Message, now time.Time) ([]byte, error) {
+	// Перевод строки в заголовке позволил бы подставить свои заголовки
+	for _, v := range []string{from, msg.To, msg.Subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, errors.New("mail header contains a line break")
+		}
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	domain := "localhost"
+	if i := strings.LastIndex(from, "@"); i >= 0 {
+		domain = from[i+1:]
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(id), domain)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+	body := strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n")
+	if _, err := w.Write([]byte(body)); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
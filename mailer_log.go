@@ -0,0 +1,57 @@
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// Log пишет письма в журнал вместо отправки; режим для локальной разработки
+type Log struct {
+	from string
+}
+
+func NewLog(from string) *Log {
+	return &Log{from: from}
+}
+
+func (m *Log) Send(_ context.Context, msg Message) error {
+	slog.Info("mail", "from", m.from, "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
+
+// File сохраняет каждое письмо в отдельный .eml файл, который можно открыть
+// почтовым клиентом
+type File struct {
+	dir  string
+	from string
+}
+
+func NewFile(dir, from string) (*File, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create mail directory: %w", err)
+	}
+	return &File{dir: dir, from: from}, nil
+}
+
+func (m *File) Send(_ context.Context, msg Message) error {
+	now := time.Now()
+	data, err := build(m.from, msg, now)
+	if err != nil {
+		return err
+	}
+
+	// Имя файла сортируется по времени отправки
+	f, err := os.CreateTemp(m.dir, now.UTC().Format("20060102T150405.000000000")+"-*.eml")
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	return f.Close()
+}
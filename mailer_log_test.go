@@ -0,0 +1,61 @@
+package mailer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSend(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFile(dir, "no-reply@example.com")
+	if err != nil {
+		t.Fatalf("NewFile: %v", err)
+	}
+
+	for _, subject := range []string{"Первое", "Второе"} {
+		if err := m.Send(context.Background(), Message{To: "user@example.com", Subject: subject, Body: "Текст"}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("files = %v, want 2 messages", files)
+	}
+
+	// Glob возвращает имена по порядку, а они сортируются по времени отправки
+	for i, subject := range []string{"Первое", "Второе"} {
+		data, err := os.ReadFile(files[i])
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, got, body := parseMessage(t, data)
+		if got != subject || body != "Текст" {
+			t.Errorf("%s: subject = %q, body = %q", files[i], got, body)
+		}
+	}
+}
+
+func TestFileSendRejectsInvalidMessage(t *testing.T) {
+	dir := t.TempDir()
+	m, err := NewFile(dir, "no-reply@example.com")
+	if err != nil {
+		t.Fatalf("NewFile: %v", err)
+	}
+
+	if err := m.Send(context.Background(), Message{To: "user@example.com", Subject: "a\r\nb"}); err == nil {
+		t.Fatal("expected error")
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("invalid message was written: %v", entries)
+	}
+}
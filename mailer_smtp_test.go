@@ -0,0 +1,232 @@
+package mailer
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeSMTP принимает одно соединение и ведет диалог SMTP без шифрования
+type fakeSMTP struct {
+	ln net.Listener
+	// rejectRcpt - отклонять получателя кодом 550
+	rejectRcpt bool
+	// auth - объявлять расширение AUTH PLAIN
+	auth bool
+
+	done     chan struct{}
+	commands []string
+	data     string
+}
+
+func newFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	return &fakeSMTP{ln: ln, done: make(chan struct{})}
+}
+
+func (s *fakeSMTP) config() SMTPConfig {
+	addr := s.ln.Addr().(*net.TCPAddr)
+	return SMTPConfig{Host: "127.0.0.1", Port: addr.Port, From: "no-reply@example.com"}
+}
+
+func (s *fakeSMTP) serve() {
+	defer close(s.done)
+
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		for _, line := range lines {
+			conn.Write([]byte(line + "\r\n"))
+		}
+	}
+
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		s.commands = append(s.commands, cmd)
+
+		switch verb, _, _ := strings.Cut(strings.ToUpper(cmd), " "); verb {
+		case "EHLO":
+			if s.auth {
+				reply("250-localhost", "250 AUTH PLAIN")
+			} else {
+				reply("250 localhost")
+			}
+		case "AUTH":
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			reply("250 OK")
+		case "RCPT":
+			if s.rejectRcpt {
+				reply("550 No such user")
+			} else {
+				reply("250 OK")
+			}
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				line, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(line, "."))
+			}
+			s.data = data.String()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPSend(t *testing.T) {
+	srv := newFakeSMTP(t)
+	srv.auth = true
+	go srv.serve()
+
+	cfg := srv.config()
+	cfg.Username, cfg.Password = "user", "secret"
+	m, err := NewSMTP(cfg)
+	if err != nil {
+		t.Fatalf("NewSMTP: %v", err)
+	}
+
+	msg := Message{To: "user@example.com", Subject: "Сброс пароля", Body: "Ваша ссылка:\n.точка в начале строки"}
+	if err := m.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	<-srv.done
+
+	want := []string{
+		"AUTH PLAIN " + base64.StdEncoding.EncodeToString([]byte("\x00user\x00secret")),
+		"MAIL FROM:<no-reply@example.com>",
+		"RCPT TO:<user@example.com>",
+		"DATA",
+		"QUIT",
+	}
+	// Первая команда - EHLO с произвольным именем клиента
+	if len(srv.commands) != len(want)+1 || !strings.HasPrefix(srv.commands[0], "EHLO ") {
+		t.Fatalf("commands = %q", srv.commands)
+	}
+	for i, cmd := range want {
+		if got := srv.commands[i+1]; !strings.HasPrefix(got, cmd) {
+			t.Errorf("command %d = %q, want %q", i+1, got, cmd)
+		}
+	}
+
+	_, subject, body := parseMessage(t, []byte(srv.data))
+	if subject != msg.Subject {
+		t.Errorf("subject = %q, want %q", subject, msg.Subject)
+	}
+	// Команда DATA завершает тело переводом строки перед точкой
+	body = strings.TrimSuffix(body, "\r\n")
+	if want := strings.ReplaceAll(msg.Body, "\n", "\r\n"); body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+}
+
+func TestSMTPSendWithoutAuth(t *testing.T) {
+	srv := newFakeSMTP(t)
+	go srv.serve()
+
+	m, err := NewSMTP(srv.config())
+	if err != nil {
+		t.Fatalf("NewSMTP: %v", err)
+	}
+	if err := m.Send(context.Background(), Message{To: "user@example.com", Subject: "Hi", Body: "Hi"}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	<-srv.done
+
+	for _, cmd := range srv.commands {
+		if strings.HasPrefix(cmd, "AUTH") {
+			t.Errorf("unexpected %q without username", cmd)
+		}
+	}
+}
+
+func TestSMTPSendRejectedRecipient(t *testing.T) {
+	srv := newFakeSMTP(t)
+	srv.rejectRcpt = true
+	go srv.serve()
+
+	m, err := NewSMTP(srv.config())
+	if err != nil {
+		t.Fatalf("NewSMTP: %v", err)
+	}
+	err = m.Send(context.Background(), Message{To: "missing@example.com", Subject: "Hi", Body: "Hi"})
+	if err == nil || !strings.Contains(err.Error(), "550") {
+		t.Fatalf("err = %v, want 550 reply", err)
+	}
+}
+
+func TestSMTPSendRejectsHeaderInjection(t *testing.T) {
+	srv := newFakeSMTP(t)
+	go srv.serve()
+
+	m, err := NewSMTP(srv.config())
+	if err != nil {
+		t.Fatalf("NewSMTP: %v", err)
+	}
+	err = m.Send(context.Background(), Message{To: "user@example.com\r\nRCPT TO:<victim@example.com>", Subject: "Hi"})
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	// Письмо отклоняется до подключения к серверу
+	srv.ln.Close()
+	<-srv.done
+	if len(srv.commands) != 0 {
+		t.Errorf("commands = %q, want none", srv.commands)
+	}
+}
+
+func TestNewSMTPRequiresHost(t *testing.T) {
+	if _, err := NewSMTP(SMTPConfig{Port: 25}); err == nil {
+		t.Error("expected error without host")
+	}
+}
+
+func TestFromEnvSMTPPort(t *testing.T) {
+	t.Setenv("MAIL_BACKEND", "smtp")
+	t.Setenv("SMTP_HOST", "localhost")
+	for _, port := range []string{"abc", "0", "-1"} {
+		t.Setenv("SMTP_PORT", port)
+		if _, err := FromEnv(); err == nil {
+			t.Errorf("SMTP_PORT=%s: expected error", port)
+		}
+	}
+
+	t.Setenv("SMTP_PORT", strconv.Itoa(1025))
+	m, err := FromEnv()
+	if err != nil {
+		t.Fatalf("FromEnv: %v", err)
+	}
+	if s, ok := m.(*SMTP); !ok || s.cfg.Port != 1025 {
+		t.Errorf("mailer = %#v", m)
+	}
+}
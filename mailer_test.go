@@ -0,0 +1,106 @@
+package mailer
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseMessage разбирает письмо и возвращает его с раскодированными темой и телом
+func parseMessage(t *testing.T, data []byte) (*mail.Message, string, string) {
+	t.Helper()
+	m, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject"))
+	if err != nil {
+		t.Fatalf("decode subject: %v", err)
+	}
+	if got := m.Header.Get("Content-Transfer-Encoding"); got != "quoted-printable" {
+		t.Fatalf("Content-Transfer-Encoding = %q", got)
+	}
+	body, err := io.ReadAll(quotedprintable.NewReader(m.Body))
+	if err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	return m, subject, string(body)
+}
+
+func TestBuild(t *testing.T) {
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	msg := Message{
+		To:      "user@example.com",
+		Subject: "Подтверждение адреса",
+		Body:    "Здравствуйте!\nПерейдите по ссылке: https://example.com/verify?token=abc=def\n" + strings.Repeat("длинная строка ", 10),
+	}
+
+	data, err := build("no-reply@example.com", msg, now)
+	if err != nil {
+		t.Fatalf("build: %v", err)
+	}
+
+	// Заголовки письма должны быть в ASCII, кириллица - только в закодированном виде
+	header, _, _ := bytes.Cut(data, []byte("\r\n\r\n"))
+	for _, b := range header {
+		if b >= 0x80 {
+			t.Fatalf("header contains non-ASCII byte: %q", header)
+		}
+	}
+	if !strings.Contains(string(header), "Subject: =?utf-8?q?") {
+		t.Errorf("subject is not RFC 2047 encoded: %q", header)
+	}
+	// Строки quoted-printable не длиннее 76 символов
+	for _, line := range strings.Split(string(data), "\r\n") {
+		if len(line) > 76 && !strings.HasPrefix(line, "Subject:") {
+			t.Errorf("line is too long: %q", line)
+		}
+	}
+
+	m, subject, body := parseMessage(t, data)
+	if subject != msg.Subject {
+		t.Errorf("subject = %q, want %q", subject, msg.Subject)
+	}
+	if want := strings.ReplaceAll(msg.Body, "\n", "\r\n"); body != want {
+		t.Errorf("body = %q, want %q", body, want)
+	}
+	if got := m.Header.Get("From"); got != "no-reply@example.com" {
+		t.Errorf("From = %q", got)
+	}
+	if got := m.Header.Get("To"); got != "user@example.com" {
+		t.Errorf("To = %q", got)
+	}
+	if got := m.Header.Get("Date"); got != now.Format(time.RFC1123Z) {
+		t.Errorf("Date = %q", got)
+	}
+	if got := m.Header.Get("Message-ID"); !strings.HasSuffix(got, "@example.com>") {
+		t.Errorf("Message-ID = %q", got)
+	}
+	if got := m.Header.Get("Content-Type"); got != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+}
+
+func TestBuildRejectsHeaderInjection(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		msg  Message
+	}{
+		{"subject", "no-reply@example.com", Message{To: "user@example.com", Subject: "Hi\r\nBcc: victim@example.com"}},
+		{"to", "no-reply@example.com", Message{To: "user@example.com\nBcc: victim@example.com", Subject: "Hi"}},
+		{"from", "no-reply@example.com\rBcc: victim@example.com", Message{To: "user@example.com", Subject: "Hi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := build(tt.from, tt.msg, time.Now()); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
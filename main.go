@@ -1,174 +1,320 @@
-package main
-
-import (
-	"context"
-	"database/sql"
-	"log"
-	"log/slog"
-	"net/http"
-	"os"
-
-	_ "golang-test/docs" // Импортируем сгенерированную документацию
-
-	"github.com/gin-gonic/gin"
-
-	"golang-test/internal/db"
-	"golang-test/internal/handlers"
-	"golang-test/internal/middleware"
-	"golang-test/internal/repository"
-
-	"github.com/pressly/goose/v3"
-	swaggerFiles "github.com/swaggo/files"
-	ginSwagger "github.com/swaggo/gin-swagger"
-)
-
-// @title Golang Test API
-// @version 1.0
-// @description API для управления объявлениями и пользователями
-// @termsOfService http://swagger.io/terms/
-
-// @contact.name API Support
-// @contact.email support@example.com
-
-// @license.name Apache 2.0
-// @license.url http://www.apache.org/licenses/LICENSE-2.0.html
-
-// @host localhost:8080
-// @BasePath /
-// @schemes http
-
-// @securityDefinitions.apikey APIKey
-// @in header
-// @name Key
-func runMigrations(db *sql.DB) error {
-	goose.SetDialect("postgres")
-	if _, err := os.Stat("migrations"); os.IsNotExist(err) {
-		if err := os.Mkdir("migrations", 0755); err != nil {
-			return err
-		}
-		slog.Info("created migrations directory")
-	}
-	return goose.Up(db, "migrations")
-}
-
-func createUploadsDir() error {
-	dirs := []string{"uploads", "uploads/images"}
-	for _, dir := range dirs {
-		if _, err := os.Stat(dir); os.IsNotExist(err) {
-			if err := os.Mkdir(dir, 0755); err != nil {
-				return err
-			}
-			slog.Info("created directory", "dir", dir)
-		}
-	}
-	return nil
-}
-
-// @Summary Проверка работоспособности сервера
-// @Description Health check endpoint
-// @Tags health
-// @Accept json
-// @Produce json
-// @Success 200 {object} map[string]string
-// @Router /health [get]
-func healthCheck(c *gin.Context) {
-	c.JSON(200, gin.H{"status": "ok"})
-}
-
-// @Summary Инициализация категорий
-// @Description Добавляет тестовые категории в базу данных
-// @Tags categories
-// @Accept json
-// @Produce json
-// @Security APIKey
-// @Success 200 {object} map[string]string
-// @Failure 500 {object} ErrorResponse
-// @Router /init-categories [get]
-func initCategories(c *gin.Context, database *sql.DB) {
-	ctx := c.Request.Context()
-	_, err := database.ExecContext(ctx, `
-		INSERT INTO categories (name, extra_property) 
-		VALUES 
-			('Electronics', 'Color: Black'),
-			('Clothing', 'Size: M')
-		ON CONFLICT (name) DO NOTHING
-	`)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
-	}
-	c.JSON(http.StatusOK, gin.H{"status": "categories initialized"})
-}
-
-func main() {
-	// Создаем директории для загрузки файлов
-	if err := createUploadsDir(); err != nil {
-		slog.Error("failed to create uploads directory", "error", err)
-		os.Exit(1)
-	}
-
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	dsn := "host=localhost user=postgres password=123 dbname=golang_db port=5432 sslmode=disable"
-
-	database, err := db.Connect(ctx, dsn)
-	if err != nil {
-		slog.Error("failed to connect to database", "error", err)
-		os.Exit(1)
-	}
-	defer database.Close()
-
-	if err := runMigrations(database); err != nil {
-		slog.Error("failed to run migrations", "error", err)
-		os.Exit(1)
-	}
-
-	slog.Info("database migrations applied successfully")
-
-	// Инициализируем репозитории
-	adRepo := repository.NewAdRepository(database)
-	userRepo := repository.NewUserRepository(database)
-
-	// Инициализируем обработчики
-	adHandler := handlers.NewAdHandler(adRepo)
-	userHandler := handlers.NewUserHandler(userRepo)
-
-	r := gin.Default()
-
-	// Добавляем Swagger UI
-	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-
-	// Добавляем middleware авторизации ко всем маршрутам
-	r.Use(middleware.AuthMiddleware())
-
-	// Маршруты для объявлений
-	adRoutes := r.Group("/ads")
-	{
-		adRoutes.GET("/:id", adHandler.GetAdByID)
-		adRoutes.GET("", adHandler.GetAllAds)
-		adRoutes.POST("", adHandler.CreateAd)
-		adRoutes.PUT("/:id", adHandler.UpdateAd)
-		adRoutes.PATCH("/:id/toggle", adHandler.ToggleAd)
-		adRoutes.DELETE("/:id", adHandler.DeleteAd)
-	}
-
-	// Маршруты для пользователей
-	userRoutes := r.Group("/users")
-	{
-		userRoutes.POST("", userHandler.CreateUser)
-		userRoutes.DELETE("/:id", userHandler.DeleteUser)
-	}
-
-	// Добавляем тестовые данные для категорий
-	r.GET("/init-categories", func(c *gin.Context) {
-		initCategories(c, database)
-	})
-
-	// Health check
-	r.GET("/health", healthCheck)
-
-	log.Println("Server started on :8080")
-	log.Println("Swagger UI available at: http://localhost:8080/swagger/index.html")
-	r.Run(":8080")
-}
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"log/slog"
+	"os"
+
+	_ "golang-test/docs" // Импортируем сгенерированную документацию
+
+	"github.com/gin-gonic/gin"
+
+	"golang-test/internal/auth"
+	"golang-test/internal/db"
+	"golang-test/internal/handlers"
+	"golang-test/internal/imagegc"
+	"golang-test/internal/imageurl"
+	"golang-test/internal/mailer"
+	"golang-test/internal/middleware"
+	"golang-test/internal/models"
+	"golang-test/internal/purge"
+	"golang-test/internal/repository"
+	"golang-test/internal/storage"
+	"golang-test/internal/upload"
+	"golang-test/internal/userdata"
+
+	"github.com/pressly/goose/v3"
+	swaggerFiles "github.com/swaggo/files"
+	ginSwagger "github.com/swaggo/gin-swagger"
+)
+
+// @title Golang Test API
+// @version 1.0
+// @description API для управления объявлениями и пользователями
+// @termsOfService http://swagger.io/terms/
+
+// @contact.name API Support
+// @contact.email support@example.com
+
+// @license.name Apache 2.0
+// @license.url http://www.apache.org/licenses/LICENSE-2.0.html
+
+// @host localhost:8080
+// @BasePath /
+// @schemes http
+
+// @securityDefinitions.apikey BearerAuth
+// @in header
+// @name Authorization
+// @description Access-токен из /auth/login в виде "Bearer <токен>"
+
+// @securityDefinitions.apikey APIKey
+// @in header
+// @name X-API-Key
+// @description API-ключ из POST /api-keys
+func runMigrations(db *sql.DB) error {
+	goose.SetDialect("postgres")
+	if _, err := os.Stat("migrations"); os.IsNotExist(err) {
+		if err := os.Mkdir("migrations", 0755); err != nil {
+			return err
+		}
+		slog.Info("created migrations directory")
+	}
+	return goose.Up(db, "migrations")
+}
+
+// @Summary Проверка работоспособности сервера
+// @Description Health check endpoint
+// @Tags health
+// @Accept json
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Router /health [get]
+func healthCheck(c *gin.Context) {
+	c.JSON(200, gin.H{"status": "ok"})
+}
+
+func main() {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Хранилище изображений: локальный каталог или S3 (см. storage.FromEnv)
+	store, err := storage.FromEnv(ctx)
+	if err != nil {
+		slog.Error("failed to init image storage", "error", err)
+		os.Exit(1)
+	}
+
+	dsn := "host=localhost user=postgres password=123 dbname=golang_db port=5432 sslmode=disable"
+
+	database, err := db.Connect(ctx, dsn)
+	if err != nil {
+		slog.Error("failed to connect to database", "error", err)
+		os.Exit(1)
+	}
+	defer database.Close()
+
+	if err := runMigrations(database); err != nil {
+		slog.Error("failed to run migrations", "error", err)
+		os.Exit(1)
+	}
+
+	slog.Info("database migrations applied successfully")
+
+	// Сервис проверки и сохранения загружаемых изображений
+	uploadConfig := upload.DefaultConfig()
+	uploads := upload.NewService(uploadConfig, store)
+
+	// Сборка файлов изображений, на которые не ссылается ни одно объявление
+	gcConfig, err := imagegc.ConfigFromEnv()
+	if err != nil {
+		slog.Error("failed to init image gc", "error", err)
+		os.Exit(1)
+	}
+
+	// Окончательная очистка удаленных пользователей и объявлений
+	purgeConfig, err := purge.ConfigFromEnv()
+	if err != nil {
+		slog.Error("failed to init purge", "error", err)
+		os.Exit(1)
+	}
+
+	// Выгрузка и стирание данных пользователей по их запросу
+	userDataConfig, err := userdata.ConfigFromEnv()
+	if err != nil {
+		slog.Error("failed to init user data jobs", "error", err)
+		os.Exit(1)
+	}
+
+	// Служебные команды: go run ./cmd/server <команда>
+	if len(os.Args) > 1 {
+		deps := &commandDeps{db: database, store: store, uploads: uploads, gc: gcConfig, purge: purgeConfig}
+		if err := runCommand(ctx, deps, os.Args[1], os.Args[2:]); err != nil {
+			slog.Error("command failed", "command", os.Args[1], "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	imagegc.NewCollector(database, store, gcConfig.Grace).Start(ctx, gcConfig.Interval, gcConfig.DryRun)
+
+	// Инициализируем репозитории
+	adRepo := repository.NewAdRepository(database, store)
+	userRepo := repository.NewUserRepository(database)
+	sessionRepo := repository.NewSessionRepository(database)
+	roleRepo := repository.NewRoleRepository(database)
+	apiKeyRepo := repository.NewAPIKeyRepository(database)
+	emailTokenRepo := repository.NewEmailTokenRepository(database)
+	categoryRepo := repository.NewCategoryRepository(database)
+	userDataRepo := repository.NewUserDataRepository(database)
+
+	purge.NewPurger(adRepo, userRepo, purgeConfig.Retention).Start(ctx, purgeConfig.Interval)
+	userdata.NewWorker(userDataRepo, userRepo, adRepo, store, userDataConfig).Start(ctx, userDataConfig.Interval)
+
+	// Ключ подписи и сроки жизни токенов (см. auth.ConfigFromEnv)
+	authConfig, err := auth.ConfigFromEnv()
+	if err != nil {
+		slog.Error("failed to init auth", "error", err)
+		os.Exit(1)
+	}
+	tokens := auth.NewTokens(authConfig)
+
+	// Абсолютные (и при наличии IMAGE_URL_SECRET подписанные) ссылки на изображения
+	imageURLs, err := imageurl.FromEnv()
+	if err != nil {
+		slog.Error("failed to init image urls", "error", err)
+		os.Exit(1)
+	}
+
+	// Отправка писем (см. mailer.FromEnv); по умолчанию письма пишутся в журнал
+	mail, err := mailer.FromEnv()
+	if err != nil {
+		slog.Error("failed to init mailer", "error", err)
+		os.Exit(1)
+	}
+
+	// Политика удаления пользователей с объявлениями по умолчанию
+	deletionPolicy := models.DeletionCascade
+	if v := os.Getenv("USER_DELETION_POLICY"); v != "" {
+		if !models.IsDeletionPolicy(v) {
+			slog.Error("invalid USER_DELETION_POLICY", "policy", v)
+			os.Exit(1)
+		}
+		deletionPolicy = v
+	}
+
+	// Инициализируем обработчики
+	adHandler := handlers.NewAdHandler(adRepo, userRepo, uploads, imageURLs)
+	imageHandler := handlers.NewImageHandler(store, imageURLs)
+	authHandler := handlers.NewAuthHandler(userRepo, sessionRepo, emailTokenRepo, tokens, mail, imageURLs.BaseURL())
+	userHandler := handlers.NewUserHandler(userRepo, deletionPolicy, authHandler.SendVerification)
+	roleHandler := handlers.NewRoleHandler(roleRepo)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyRepo)
+	categoryHandler := handlers.NewCategoryHandler(categoryRepo)
+	userDataHandler := handlers.NewUserDataHandler(userDataRepo, userDataConfig.Dir)
+
+	r := gin.Default()
+
+	// Добавляем Swagger UI
+	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
+
+	// Изображения доступны без токена, чтобы их можно было вставлять в <img>;
+	// доступ при необходимости ограничивается подписью ссылки
+	r.GET("/images/:filename", imageHandler.ServeImage)
+	r.HEAD("/images/:filename", imageHandler.ServeImage)
+
+	// Health check
+	r.GET("/health", healthCheck)
+
+	// Публичный каталог: только включенные объявления, без контактных данных
+	publicRoutes := r.Group("/public/ads")
+	{
+		publicRoutes.GET("/search", adHandler.SearchPublicAds)
+		publicRoutes.GET("/:id", adHandler.GetPublicAdByID)
+		publicRoutes.GET("", adHandler.GetPublicAds)
+	}
+
+	// Категории нужны для фильтров публичного каталога, поэтому читаются без токена
+	r.GET("/categories", categoryHandler.GetCategories)
+	r.GET("/categories/tree", categoryHandler.GetCategoryTree)
+	r.GET("/categories/:id", categoryHandler.GetCategory)
+
+	// Регистрация, вход, обновление токенов и ссылки из писем доступны без авторизации
+	authRoutes := r.Group("/auth")
+	{
+		authRoutes.POST("/register", authHandler.Register)
+		authRoutes.POST("/login", authHandler.Login)
+		authRoutes.POST("/refresh", authHandler.Refresh)
+		authRoutes.GET("/verify-email", authHandler.VerifyEmail)
+		authRoutes.POST("/password-reset", authHandler.RequestPasswordReset)
+		authRoutes.POST("/password-reset/confirm", authHandler.ConfirmPasswordReset)
+	}
+
+	// Добавляем middleware авторизации ко всем последующим маршрутам
+	r.Use(middleware.AuthMiddleware(tokens, sessionRepo, apiKeyRepo))
+
+	// Маршруты текущей сессии
+	sessionRoutes := r.Group("/auth")
+	{
+		sessionRoutes.GET("/me", authHandler.Me)
+		sessionRoutes.POST("/verify-email/resend", authHandler.ResendVerification)
+		sessionRoutes.POST("/logout", authHandler.Logout)
+		sessionRoutes.POST("/logout/all", authHandler.LogoutAll)
+	}
+
+	// API-ключи текущего пользователя; управлять ими можно только из сессии
+	apiKeyRoutes := r.Group("/api-keys")
+	{
+		apiKeyRoutes.POST("", apiKeyHandler.CreateAPIKey)
+		apiKeyRoutes.GET("", apiKeyHandler.GetAPIKeys)
+		apiKeyRoutes.DELETE("/:id", apiKeyHandler.RevokeAPIKey)
+		apiKeyRoutes.GET("/:id/audit", apiKeyHandler.GetAPIKeyAudit)
+	}
+
+	// Маршруты для объявлений
+	// Тело запроса не больше максимального числа изображений плюс запас на поля формы
+	adRoutes := r.Group("/ads", middleware.MaxBodySize(int64(models.MaxAdImages)*uploadConfig.MaxFileSize+1<<20))
+	{
+		adRoutes.GET("/search", adHandler.SearchAds)
+		adRoutes.GET("/:id", adHandler.GetAdByID)
+		adRoutes.GET("", adHandler.GetAllAds)
+	}
+
+	// Изменять можно свои объявления; чужие - с правами ads:moderate
+	// (включение/выключение) и ads:manage (все остальное), это проверяют обработчики
+	adWriteRoutes := adRoutes.Group("", middleware.RequirePermission(models.PermAdsWrite))
+	{
+		adWriteRoutes.POST("", adHandler.CreateAd)
+		adWriteRoutes.PUT("/:id", adHandler.UpdateAd)
+		adWriteRoutes.PATCH("/:id/toggle", adHandler.ToggleAd)
+		adWriteRoutes.DELETE("/:id", adHandler.DeleteAd)
+		adWriteRoutes.POST("/:id/restore", middleware.RequirePermission(models.PermAdsManage), adHandler.RestoreAd)
+		adWriteRoutes.POST("/:id/images", adHandler.AddAdImages)
+		adWriteRoutes.PUT("/:id/images/order", adHandler.ReorderAdImages)
+		adWriteRoutes.PATCH("/:id/images/:imageId/cover", adHandler.SetAdCoverImage)
+		adWriteRoutes.DELETE("/:id/images/:imageId", adHandler.DeleteAdImage)
+	}
+
+	// Маршруты для пользователей
+	// Свой профиль можно читать и менять, выгружать и стирать свои данные
+	// без users:manage, это проверяют обработчики
+	manageUsers := middleware.RequirePermission(models.PermUsersManage)
+	userRoutes := r.Group("/users")
+	{
+		userRoutes.GET("", manageUsers, userHandler.GetUsers)
+		userRoutes.POST("", manageUsers, userHandler.CreateUser)
+		userRoutes.GET("/:id", userHandler.GetUser)
+		userRoutes.PATCH("/:id", userHandler.UpdateUser)
+		userRoutes.DELETE("/:id", manageUsers, userHandler.DeleteUser)
+		userRoutes.POST("/:id/restore", manageUsers, userHandler.RestoreUser)
+		userRoutes.GET("/:id/ads", adHandler.GetUserAds)
+		userRoutes.POST("/:id/export", userDataHandler.RequestExport)
+		userRoutes.GET("/:id/export", userDataHandler.GetExport)
+		userRoutes.POST("/:id/erasure", userDataHandler.RequestErasure)
+		userRoutes.GET("/:id/data-jobs", userDataHandler.GetDataJobs)
+		userRoutes.GET("/:id/data-jobs/:jobId", userDataHandler.GetDataJob)
+		userRoutes.GET("/:id/data-jobs/:jobId/download", userDataHandler.DownloadDataJob)
+	}
+
+	// Управление категориями; начальный набор добавляется миграцией и командой seed-categories
+	categoryRoutes := r.Group("/categories", middleware.RequirePermission(models.PermCategoriesManage))
+	{
+		categoryRoutes.POST("", categoryHandler.CreateCategory)
+		categoryRoutes.PUT("/:id", categoryHandler.UpdateCategory)
+		categoryRoutes.DELETE("/:id", categoryHandler.DeleteCategory)
+	}
+
+	// Назначение ролей
+	adminRoutes := r.Group("/admin", middleware.RequirePermission(models.PermRolesManage))
+	{
+		adminRoutes.GET("/roles", roleHandler.GetRoles)
+		adminRoutes.GET("/users/:id/roles", roleHandler.GetUserRoles)
+		adminRoutes.PUT("/users/:id/roles", roleHandler.SetUserRoles)
+	}
+
+	log.Println("Server started on :8080")
+	log.Println("Swagger UI available at: http://localhost:8080/swagger/index.html")
+	r.Run(":8080")
+}
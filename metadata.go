@@ -0,0 +1,221 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+)
+
+// Качество, с которым перекодируются оригиналы JPEG при удалении метаданных
+const originalQuality = 92
+
+// Orientation возвращает значение тега EXIF Orientation (1-8) из JPEG.
+// Если тега нет или данные повреждены, возвращается 1 (без поворота).
+func Orientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 1
+		}
+		marker := data[pos+1]
+		// SOS: дальше идут сжатые данные, метаданных там нет
+		if marker == 0xDA || marker == 0xD9 {
+			return 1
+		}
+		size := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if size < 2 || pos+2+size > len(data) {
+			return 1
+		}
+		segment := data[pos+4 : pos+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		pos += 2 + size
+	}
+
+	return 1
+}
+
+// tiffOrientation ищет тег 0x0112 в IFD0 блока TIFF
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			v := int(order.Uint16(tiff[entry+8:]))
+			if v < 1 || v > 8 {
+				return 1
+			}
+			return v
+		}
+	}
+
+	return 1
+}
+
+// ApplyOrientation поворачивает и отражает пиксели согласно тегу Orientation,
+// чтобы изображение отображалось правильно без метаданных
+func ApplyOrientation(src image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return src
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+
+	// Ориентации 5-8 меняют ширину и высоту местами
+	dr := image.Rect(0, 0, w, h)
+	if orientation >= 5 {
+		dr = image.Rect(0, 0, h, w)
+	}
+
+	// Пиксели переставляются целиком по 4 байта. NRGBA копируется как есть,
+	// остальные типы, в том числе YCbCr из JPEG, сначала переводятся в RGBA
+	// через draw.Draw, у которого для них есть быстрые пути
+	if nrgba, ok := src.(*image.NRGBA); ok {
+		dst := image.NewNRGBA(dr)
+		orient(dst.Pix, dst.Stride, nrgba.Pix[nrgba.PixOffset(b.Min.X, b.Min.Y):], nrgba.Stride, w, h, orientation)
+		return dst
+	}
+
+	rgba := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(rgba, rgba.Bounds(), src, b.Min, draw.Src)
+	dst := image.NewRGBA(dr)
+	orient(dst.Pix, dst.Stride, rgba.Pix, rgba.Stride, w, h, orientation)
+	return dst
+}
+
+// orient копирует 4-байтовые пиксели изображения w x h из src в dst.
+// Смещение пикселя (x, y) в dst линейно зависит от координат:
+// start + x*stepX + y*stepY, поэтому ориентация выбирается один раз.
+func orient(dst []byte, dstStride int, src []byte, srcStride, w, h, orientation int) {
+	var start, stepX, stepY int
+	switch orientation {
+	case 2: // отражение по горизонтали
+		start, stepX, stepY = (w-1)*4, -4, dstStride
+	case 3: // поворот на 180
+		start, stepX, stepY = (h-1)*dstStride+(w-1)*4, -4, -dstStride
+	case 4: // отражение по вертикали
+		start, stepX, stepY = (h-1)*dstStride, 4, -dstStride
+	case 5: // транспонирование
+		start, stepX, stepY = 0, dstStride, 4
+	case 6: // поворот на 90 по часовой
+		start, stepX, stepY = (h-1)*4, dstStride, -4
+	case 7: // транспонирование относительно побочной диагонали
+		start, stepX, stepY = (w-1)*dstStride+(h-1)*4, -dstStride, -4
+	case 8: // поворот на 90 против часовой
+		start, stepX, stepY = (w-1)*dstStride, -dstStride, 4
+	}
+
+	for y := 0; y < h; y++ {
+		row := src[y*srcStride : y*srcStride+w*4]
+		d := start + y*stepY
+		for x := 0; x < w*4; x += 4 {
+			copy(dst[d:d+4], row[x:x+4])
+			d += stepX
+		}
+	}
+}
+
+// HasMetadata сообщает, содержит ли файл метаданные: сегменты APPn (кроме JFIF)
+// и комментарии в JPEG, текстовые, EXIF и временные блоки в PNG
+func HasMetadata(data []byte) bool {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xFF, 0xD8}):
+		pos := 2
+		for pos+4 <= len(data) && data[pos] == 0xFF {
+			marker := data[pos+1]
+			if marker == 0xDA || marker == 0xD9 {
+				return false
+			}
+			if (marker >= 0xE1 && marker <= 0xEF) || marker == 0xFE {
+				return true
+			}
+			pos += 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		}
+		return false
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		pos := 8
+		for pos+8 <= len(data) {
+			length := int(binary.BigEndian.Uint32(data[pos:]))
+			switch string(data[pos+4 : pos+8]) {
+			case "tEXt", "zTXt", "iTXt", "eXIf", "tIME":
+				return true
+			case "IEND":
+				return false
+			}
+			pos += 12 + length
+		}
+		return false
+	}
+	return false
+}
+
+// Sanitize декодирует изображение, применяет EXIF-ориентацию и кодирует его
+// заново в том же формате. Кодировщики стандартной библиотеки не записывают
+// метаданные, поэтому EXIF, XMP и GPS-координаты в результат не попадают.
+func Sanitize(data []byte) ([]byte, image.Image, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	if format == "jpeg" {
+		img = ApplyOrientation(img, Orientation(data))
+	}
+
+	out, err := Encode(img, format)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, img, nil
+}
+
+// Encode кодирует изображение в формате "jpeg" или "png"
+func Encode(img image.Image, format string) ([]byte, error) {
+	var buf bytes.Buffer
+	switch format {
+	case "jpeg":
+		if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: originalQuality}); err != nil {
+			return nil, err
+		}
+	case "png":
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	return buf.Bytes(), nil
+}
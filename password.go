@@ -0,0 +1,38 @@
+package auth
+
+import (
+	"errors"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// ErrInvalidPassword возвращается, если пароль не совпадает с хешем
+var ErrInvalidPassword = errors.New("invalid password")
+
+// dummyHash используется, когда пользователь не найден: сравнение с ним занимает
+// столько же времени, сколько проверка настоящего пароля, и по времени ответа
+// нельзя узнать, зарегистрирован ли email
+const dummyHash = "$2a$10$AD4muoHCGmV1ZN8/g8yi4.RIRFPPuGJyoBvt4jr8L/wMAj5D5BWlW"
+
+// HashPassword возвращает bcrypt-хеш пароля
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckPassword сравнивает пароль с bcrypt-хешем. Пустой хеш (пользователь
+// не найден или пароль не задан) никогда не совпадает.
+func CheckPassword(hash, password string) error {
+	if hash == "" {
+		bcrypt.CompareHashAndPassword([]byte(dummyHash), []byte(password))
+		return ErrInvalidPassword
+	}
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return ErrInvalidPassword
+	}
+	return err
+}
@@ -0,0 +1,110 @@
+package purge
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"golang-test/internal/repository"
+)
+
+// Config - настройки окончательной очистки удаленных записей
+type Config struct {
+	// Interval - период запуска; 0 отключает фоновую очистку
+	Interval time.Duration
+	// Retention - сколько удаленные записи хранятся до очистки
+	Retention time.Duration
+}
+
+// ConfigFromEnv читает настройки из переменных окружения:
+//
+//	PURGE_INTERVAL   период фоновой очистки, по умолчанию 24h; 0 отключает ее
+//	PURGE_RETENTION  срок хранения удаленных записей, по умолчанию 720h
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Interval: 24 * time.Hour, Retention: 30 * 24 * time.Hour}
+
+	if v := os.Getenv("PURGE_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, errors.New("invalid PURGE_INTERVAL")
+		}
+		cfg.Interval = d
+	}
+	if v := os.Getenv("PURGE_RETENTION"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, errors.New("invalid PURGE_RETENTION")
+		}
+		cfg.Retention = d
+	}
+	return cfg, nil
+}
+
+// Report - результат одного прохода очистки
+type Report struct {
+	// Before - удалены записи, помеченные удаленными раньше этого момента
+	Before time.Time `json:"before"`
+	Ads    int       `json:"ads"`
+	Users  int       `json:"users"`
+}
+
+// Purger окончательно удаляет записи, срок хранения которых истек
+type Purger struct {
+	ads       *repository.AdRepository
+	users     *repository.UserRepository
+	retention time.Duration
+}
+
+func NewPurger(ads *repository.AdRepository, users *repository.UserRepository, retention time.Duration) *Purger {
+	return &Purger{ads: ads, users: users, retention: retention}
+}
+
+// Run удаляет сначала пользователей вместе с их объявлениями, затем
+// оставшиеся объявления. Файлы изображений удаляются после фиксации транзакций.
+func (p *Purger) Run(ctx context.Context) (*Report, error) {
+	report := &Report{Before: time.Now().Add(-p.retention)}
+
+	users, unused, err := p.users.Purge(ctx, report.Before)
+	report.Users = users
+	p.ads.DiscardImages(ctx, unused)
+	if err != nil {
+		return report, err
+	}
+
+	report.Ads, err = p.ads.Purge(ctx, report.Before)
+	return report, err
+}
+
+// Start запускает периодическую очистку до отмены ctx; interval <= 0 отключает ее
+func (p *Purger) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			report, err := p.Run(ctx)
+			if err != nil {
+				slog.Error("purge of deleted records failed", "error", err)
+				continue
+			}
+			report.Log()
+		}
+	}()
+}
+
+// Log пишет отчет в журнал
+func (r *Report) Log() {
+	slog.Info("deleted records purged", "before", r.Before, "ads", r.Ads, "users", r.Users)
+}
@@ -0,0 +1,28 @@
+package models
+
+// Роли, создаваемые миграцией
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Права доступа, проверяемые middleware.RequirePermission и обработчиками
+const (
+	PermAdsWrite         = "ads:write"
+	PermAdsModerate      = "ads:moderate"
+	PermAdsManage        = "ads:manage"
+	PermCategoriesManage = "categories:manage"
+	PermUsersManage      = "users:manage"
+	PermRolesManage      = "roles:manage"
+)
+
+type Role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+}
+
+type UserRoles struct {
+	Roles []string `json:"roles" binding:"required"`
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"golang-test/internal/models"
+)
+
+// rowQueryer - общий интерфейс *sql.DB и *sql.Tx для запросов одной строки
+type rowQueryer interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// splitList разбирает результат string_agg
+func splitList(s string) []string {
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, ",")
+}
+
+// loadUserAccess заполняет роли и права пользователя
+func loadUserAccess(ctx context.Context, q rowQueryer, user *models.User) error {
+	var roles, permissions string
+	err := q.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(string_agg(DISTINCT r.name, ',' ORDER BY r.name), ''),
+			COALESCE(string_agg(DISTINCT p.name, ',' ORDER BY p.name), '')
+		FROM user_roles ur
+		JOIN roles r ON r.id = ur.role_id
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		WHERE ur.user_id = $1
+	`, user.ID).Scan(&roles, &permissions)
+	if err != nil {
+		return err
+	}
+
+	user.Roles = splitList(roles)
+	user.Permissions = splitList(permissions)
+	return nil
+}
+
+type RoleRepository struct {
+	db *sql.DB
+}
+
+func NewRoleRepository(db *sql.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+// GetAll возвращает все роли с их правами
+func (r *RoleRepository) GetAll(ctx context.Context) ([]models.Role, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT r.name, r.description, COALESCE(string_agg(p.name, ',' ORDER BY p.name), '')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		GROUP BY r.id
+		ORDER BY r.id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []models.Role{}
+	for rows.Next() {
+		var role models.Role
+		var permissions string
+		if err := rows.Scan(&role.Name, &role.Description, &permissions); err != nil {
+			return nil, err
+		}
+		role.Permissions = splitList(permissions)
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// GetUserRoles возвращает роли пользователя
+func (r *RoleRepository) GetUserRoles(ctx context.Context, userID int) ([]string, error) {
+	user := models.User{ID: userID}
+	var exists bool
+	err := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)", userID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("user with id %d does not exist", userID)
+	}
+
+	if err := loadUserAccess(ctx, r.db, &user); err != nil {
+		return nil, err
+	}
+	return user.Roles, nil
+}
+
+// SetUserRoles заменяет набор ролей пользователя. Нельзя оставить систему
+// без пользователя с правом назначать роли.
+func (r *RoleRepository) SetUserRoles(ctx context.Context, userID int, roles []string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", userID).Scan(&id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user with id %d does not exist", userID)
+		}
+		return err
+	}
+
+	// Назначения ролей меняются по одному, чтобы проверка последнего
+	// администратора не пропустила два параллельных снятия роли
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE user_roles IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+
+	for _, role := range roles {
+		var exists bool
+		err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM roles WHERE name = $1)", role).Scan(&exists)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("role %s does not exist", role)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_roles WHERE user_id = $1", userID); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT $1, id FROM roles WHERE name = ANY($2)
+	`, userID, roles)
+	if err != nil {
+		return err
+	}
+
+	admins, err := countAdmins(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if admins == 0 {
+		return fmt.Errorf("cannot remove the last administrator")
+	}
+
+	return tx.Commit()
+}
+
+// countAdmins считает действующих пользователей с правом назначать роли
+func countAdmins(ctx context.Context, q rowQueryer) (int, error) {
+	var admins int
+	err := q.QueryRowContext(ctx, `
+		SELECT COUNT(DISTINCT ur.user_id)
+		FROM user_roles ur
+		JOIN users u ON u.id = ur.user_id AND u.deleted_at IS NULL
+		JOIN role_permissions rp ON rp.role_id = ur.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE p.name = $1
+	`, models.PermRolesManage).Scan(&admins)
+	return admins, err
+}
@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang-test/internal/models"
+	"golang-test/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RoleHandler struct {
+	repo *repository.RoleRepository
+}
+
+func NewRoleHandler(repo *repository.RoleRepository) *RoleHandler {
+	return &RoleHandler{repo: repo}
+}
+
+// GetRoles возвращает список ролей
+// @Summary Список ролей
+// @Description Возвращает все роли и входящие в них права
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {array} models.Role
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/roles [get]
+func (h *RoleHandler) GetRoles(c *gin.Context) {
+	roles, err := h.repo.GetAll(c.Request.Context())
+	if err != nil {
+		slog.Error("failed to get roles", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// GetUserRoles возвращает роли пользователя
+// @Summary Роли пользователя
+// @Tags admin
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} models.UserRoles
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/users/{id}/roles [get]
+func (h *RoleHandler) GetUserRoles(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	roles, err := h.repo.GetUserRoles(c.Request.Context(), id)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("user with id %d does not exist", id) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "user not found",
+			})
+			return
+		}
+		slog.Error("failed to get user roles", "error", err, "id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.UserRoles{Roles: roles})
+}
+
+// SetUserRoles назначает роли пользователю
+// @Summary Назначить роли
+// @Description Заменяет набор ролей пользователя. Изменения действуют со следующего запроса пользователя
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param roles body models.UserRoles true "Новый набор ролей"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} models.UserRoles
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/users/{id}/roles [put]
+func (h *RoleHandler) SetUserRoles(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	var req models.UserRoles
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	err = h.repo.SetUserRoles(c.Request.Context(), id, req.Roles)
+	if err != nil {
+		switch {
+		case err.Error() == fmt.Sprintf("user with id %d does not exist", id):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "user not found",
+			})
+		case strings.HasPrefix(err.Error(), "role ") && strings.HasSuffix(err.Error(), " does not exist"):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+		case err.Error() == "cannot remove the last administrator":
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+		default:
+			slog.Error("failed to set user roles", "error", err, "id", id)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "internal server error",
+			})
+		}
+		return
+	}
+
+	h.GetUserRoles(c)
+}
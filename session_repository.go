@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"golang-test/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type SessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) *SessionRepository {
+	return &SessionRepository{db: db}
+}
+
+// Create открывает сессию пользователя с первым refresh-токеном и возвращает ID сессии
+func (r *SessionRepository) Create(ctx context.Context, userID int, refreshHash string, expiresAt time.Time) (string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	sessionID := uuid.New().String()
+	_, err = tx.ExecContext(ctx, "INSERT INTO auth_sessions (id, user_id) VALUES ($1, $2)", sessionID, userID)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (session_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`, sessionID, refreshHash, expiresAt)
+	if err != nil {
+		return "", err
+	}
+
+	return sessionID, tx.Commit()
+}
+
+// Rotate погашает refresh-токен и выдает вместо него новый в той же сессии.
+// Повторное использование уже погашенного токена означает, что он мог быть
+// украден, поэтому сессия отзывается целиком.
+func (r *SessionRepository) Rotate(ctx context.Context, oldHash, newHash string, expiresAt time.Time) (userID int, sessionID string, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, "", err
+	}
+	defer tx.Rollback()
+
+	var tokenID int
+	var tokenExpiresAt time.Time
+	var usedAt, revokedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, `
+		SELECT t.id, t.session_id, t.expires_at, t.used_at, s.user_id, s.revoked_at
+		FROM refresh_tokens t
+		JOIN auth_sessions s ON s.id = t.session_id
+		WHERE t.token_hash = $1
+		FOR UPDATE OF t, s
+	`, oldHash).Scan(&tokenID, &sessionID, &tokenExpiresAt, &usedAt, &userID, &revokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, "", fmt.Errorf("invalid refresh token")
+		}
+		return 0, "", err
+	}
+
+	if revokedAt.Valid || time.Now().After(tokenExpiresAt) {
+		return 0, "", fmt.Errorf("invalid refresh token")
+	}
+
+	if usedAt.Valid {
+		if _, err := tx.ExecContext(ctx, "UPDATE auth_sessions SET revoked_at = NOW() WHERE id = $1", sessionID); err != nil {
+			return 0, "", err
+		}
+		if err := tx.Commit(); err != nil {
+			return 0, "", err
+		}
+		return 0, "", fmt.Errorf("refresh token reuse detected")
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE refresh_tokens SET used_at = NOW() WHERE id = $1", tokenID); err != nil {
+		return 0, "", err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO refresh_tokens (session_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+	`, sessionID, newHash, expiresAt)
+	if err != nil {
+		return 0, "", err
+	}
+
+	return userID, sessionID, tx.Commit()
+}
+
+// Revoke отзывает сессию пользователя
+func (r *SessionRepository) Revoke(ctx context.Context, userID int, sessionID string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE auth_sessions SET revoked_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL
+	`, sessionID, userID)
+	return err
+}
+
+// RevokeAll отзывает все сессии пользователя
+func (r *SessionRepository) RevokeAll(ctx context.Context, userID int) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE auth_sessions SET revoked_at = NOW()
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`, userID)
+	return err
+}
+
+// User возвращает пользователя активной сессии с его ролями и правами или nil, если сессия отозвана
+func (r *SessionRepository) User(ctx context.Context, userID int, sessionID string) (*models.User, error) {
+	if _, err := uuid.Parse(sessionID); err != nil {
+		return nil, nil
+	}
+
+	var user models.User
+	err := r.db.QueryRowContext(ctx, `
+		SELECT u.id, u.name, u.email, u.email_verified_at, u.created_at
+		FROM users u
+		JOIN auth_sessions s ON s.user_id = u.id
+		WHERE u.id = $1 AND s.id = $2 AND s.revoked_at IS NULL AND u.deleted_at IS NULL
+	`, userID, sessionID).Scan(&user.ID, &user.Name, &user.Email, &user.EmailVerifiedAt, &user.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if err := loadUserAccess(ctx, r.db, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
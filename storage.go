@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNotFound возвращается, если объекта с таким ключом нет
+var ErrNotFound = errors.New("object not found")
+
+// Object описывает сохраненный файл
+type Object struct {
+	Key         string
+	Size        int64
+	ModTime     time.Time
+	ContentType string
+}
+
+// checkKey проверяет ключ: имя файла без каталогов, не начинающееся с точки.
+// Так ключ не выходит за пределы каталога или бакета и не совпадает
+// с временными файлами.
+func checkKey(key string) error {
+	if key == "" || key != filepath.Base(key) || strings.HasPrefix(key, ".") {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	return nil
+}
+
+// Storage - хранилище файлов изображений. Ключ - имя файла без каталогов.
+type Storage interface {
+	// Put сохраняет объект, перезаписывая существующий
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get открывает объект для чтения; вызывающий обязан закрыть его
+	Get(ctx context.Context, key string) (io.ReadSeekCloser, *Object, error)
+	// Stat возвращает сведения об объекте или ErrNotFound
+	Stat(ctx context.Context, key string) (*Object, error)
+	// Delete удаляет объект; отсутствие объекта ошибкой не считается
+	Delete(ctx context.Context, key string) error
+	// List вызывает fn для каждого объекта хранилища
+	List(ctx context.Context, fn func(Object) error) error
+}
+
+// TempStorage реализуют хранилища, которые пишут объекты через временные
+// файлы. Файлы, оставшиеся после сбоя записи, удаляет сборщик мусора.
+type TempStorage interface {
+	// ListTemp вызывает fn для каждого временного файла
+	ListTemp(ctx context.Context, fn func(Object) error) error
+	// DeleteTemp удаляет временный файл; отсутствие файла ошибкой не считается
+	DeleteTemp(ctx context.Context, key string) error
+}
+
+// FromEnv создает хранилище по переменным окружения:
+//
+//	STORAGE_BACKEND   local (по умолчанию) или s3
+//	STORAGE_DIR       каталог локального хранилища, по умолчанию uploads/images
+//	S3_ENDPOINT       адрес S3-совместимого сервиса, например localhost:9000
+//	S3_BUCKET         имя бакета, создается при отсутствии
+//	S3_ACCESS_KEY     ключ доступа
+//	S3_SECRET_KEY     секретный ключ
+//	S3_REGION         регион, необязательно
+//	S3_USE_SSL        true для HTTPS
+func FromEnv(ctx context.Context) (Storage, error) {
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", "local":
+		dir := os.Getenv("STORAGE_DIR")
+		if dir == "" {
+			dir = filepath.Join("uploads", "images")
+		}
+		return NewLocal(dir)
+	case "s3":
+		useSSL, _ := strconv.ParseBool(os.Getenv("S3_USE_SSL"))
+		return NewS3(ctx, S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+			Region:    os.Getenv("S3_REGION"),
+			UseSSL:    useSSL,
+		})
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+}
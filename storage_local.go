@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tempPrefix - префикс временных файлов незавершенной записи
+const tempPrefix = ".upload-"
+
+// Local хранит файлы в каталоге на диске
+type Local struct {
+	dir string
+}
+
+// NewLocal создает локальное хранилище, при необходимости создавая каталог
+func NewLocal(dir string) (*Local, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &Local{dir: dir}, nil
+}
+
+// path проверяет ключ, чтобы он не мог выйти за пределы каталога хранилища
+func (l *Local) path(key string) (string, error) {
+	if err := checkKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(l.dir, key), nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	// Пишем во временный файл и переименовываем, чтобы читатели не увидели частично записанный файл
+	tmp, err := os.CreateTemp(l.dir, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadSeekCloser, *Object, error) {
+	path, err := l.path(key)
+	if err != nil {
+		// Объекта с недопустимым ключом не может существовать
+		return nil, nil, ErrNotFound
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+
+	return f, localObject(key, info), nil
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (*Object, error) {
+	path, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	return localObject(key, info), nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	path, err := l.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) List(ctx context.Context, fn func(Object) error) error {
+	return l.list(ctx, false, fn)
+}
+
+// ListTemp перечисляет временные файлы, в том числе оставшиеся после сбоя
+func (l *Local) ListTemp(ctx context.Context, fn func(Object) error) error {
+	return l.list(ctx, true, fn)
+}
+
+func (l *Local) DeleteTemp(ctx context.Context, key string) error {
+	if key != filepath.Base(key) || !strings.HasPrefix(key, tempPrefix) {
+		return fmt.Errorf("invalid temp file %q", key)
+	}
+	if err := os.Remove(filepath.Join(l.dir, key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// list перечисляет объекты или, при temp, временные файлы
+func (l *Local) list(ctx context.Context, temp bool, fn func(Object) error) error {
+	entries, err := os.ReadDir(l.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := entry.Name()
+		if entry.IsDir() {
+			continue
+		}
+		if temp && !strings.HasPrefix(name, tempPrefix) {
+			continue
+		}
+		// Ключи объектов не начинаются с точки (см. path), поэтому временные
+		// и прочие скрытые файлы в список объектов не попадают
+		if !temp && strings.HasPrefix(name, ".") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		if err := fn(*localObject(name, info)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func localObject(key string, info os.FileInfo) *Object {
+	return &Object{
+		Key:         key,
+		Size:        info.Size(),
+		ModTime:     info.ModTime(),
+		ContentType: mime.TypeByExtension(filepath.Ext(key)),
+	}
+}
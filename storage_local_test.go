@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLocal(t *testing.T) {
+	s, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+	testStorage(t, s)
+}
+
+func TestLocalTempFiles(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := NewLocal(dir)
+	if err != nil {
+		t.Fatalf("NewLocal: %v", err)
+	}
+
+	if err := s.Put(ctx, "image.jpg", []byte("x"), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	// Успешная запись не оставляет временных файлов
+	if temps := listTemp(t, s); len(temps) != 0 {
+		t.Fatalf("temp files after Put = %v", temps)
+	}
+
+	// Временный файл, оставшийся после сбоя записи, и посторонний скрытый файл
+	for _, name := range []string{".upload-123", ".DS_Store"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var keys []string
+	err = s.List(ctx, func(obj Object) error {
+		keys = append(keys, obj.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if want := []string{"image.jpg"}; !slices.Equal(keys, want) {
+		t.Errorf("List = %v, want %v", keys, want)
+	}
+
+	if temps, want := listTemp(t, s), []string{".upload-123"}; !slices.Equal(temps, want) {
+		t.Errorf("ListTemp = %v, want %v", temps, want)
+	}
+
+	for _, key := range []string{"image.jpg", ".DS_Store", "../.upload-123"} {
+		if err := s.DeleteTemp(ctx, key); err == nil {
+			t.Errorf("DeleteTemp(%q): expected error", key)
+		}
+	}
+	if err := s.DeleteTemp(ctx, ".upload-123"); err != nil {
+		t.Fatalf("DeleteTemp: %v", err)
+	}
+	if temps := listTemp(t, s); len(temps) != 0 {
+		t.Errorf("temp files after DeleteTemp = %v", temps)
+	}
+	if err := s.DeleteTemp(ctx, ".upload-123"); err != nil {
+		t.Errorf("second DeleteTemp: %v", err)
+	}
+}
+
+func listTemp(t *testing.T, s *Local) []string {
+	t.Helper()
+	var keys []string
+	err := s.ListTemp(context.Background(), func(obj Object) error {
+		keys = append(keys, obj.Key)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ListTemp: %v", err)
+	}
+	return keys
+}
@@ -0,0 +1,135 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+type S3Config struct {
+	Endpoint  string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Region    string
+	UseSSL    bool
+}
+
+// S3 хранит файлы в бакете S3-совместимого сервиса (AWS S3, MinIO и т.п.)
+type S3 struct {
+	client *minio.Client
+	bucket string
+}
+
+// NewS3 подключается к сервису и создает бакет, если его еще нет
+func NewS3(ctx context.Context, cfg S3Config) (*S3, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3_ENDPOINT and S3_BUCKET are required for s3 storage")
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := client.BucketExists(ctx, cfg.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("check bucket %s: %w", cfg.Bucket, err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, cfg.Bucket, minio.MakeBucketOptions{Region: cfg.Region}); err != nil {
+			return nil, fmt.Errorf("create bucket %s: %w", cfg.Bucket, err)
+		}
+	}
+
+	return &S3{client: client, bucket: cfg.Bucket}, nil
+}
+
+func (s *S3) Put(ctx context.Context, key string, data []byte, contentType string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	_, err := s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+	return err
+}
+
+func (s *S3) Get(ctx context.Context, key string) (io.ReadSeekCloser, *Object, error) {
+	if err := checkKey(key); err != nil {
+		// Объекта с недопустимым ключом не может существовать
+		return nil, nil, ErrNotFound
+	}
+	obj, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, nil, s.translate(err)
+	}
+
+	// GetObject ленивый: ошибка отсутствия объекта появляется только при первом обращении
+	info, err := obj.Stat()
+	if err != nil {
+		obj.Close()
+		return nil, nil, s.translate(err)
+	}
+
+	return obj, s3Object(info), nil
+}
+
+func (s *S3) Stat(ctx context.Context, key string) (*Object, error) {
+	if err := checkKey(key); err != nil {
+		return nil, err
+	}
+	info, err := s.client.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		return nil, s.translate(err)
+	}
+	return s3Object(info), nil
+}
+
+func (s *S3) Delete(ctx context.Context, key string) error {
+	if err := checkKey(key); err != nil {
+		return err
+	}
+	// S3 не возвращает ошибку при удалении несуществующего объекта
+	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *S3) List(ctx context.Context, fn func(Object) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Recursive: true}) {
+		if info.Err != nil {
+			return info.Err
+		}
+		if err := fn(*s3Object(info)); err != nil {
+			return err
+		}
+	}
+
+	return ctx.Err()
+}
+
+func (s *S3) translate(err error) error {
+	if resp := minio.ToErrorResponse(err); resp.Code == "NoSuchKey" {
+		return ErrNotFound
+	}
+	return err
+}
+
+func s3Object(info minio.ObjectInfo) *Object {
+	return &Object{
+		Key:         info.Key,
+		Size:        info.Size,
+		ModTime:     info.LastModified,
+		ContentType: info.ContentType,
+	}
+}
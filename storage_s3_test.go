@@ -0,0 +1,239 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// TestS3 по умолчанию работает с fakeS3. Чтобы проверить настоящий сервис,
+// задайте S3_TEST_ENDPOINT (например, localhost:9000 для MinIO),
+// S3_TEST_ACCESS_KEY и S3_TEST_SECRET_KEY; тест создаст и удалит свой бакет.
+func TestS3(t *testing.T) {
+	testStorage(t, newTestS3(t))
+}
+
+func TestS3RequiresBucket(t *testing.T) {
+	if _, err := NewS3(context.Background(), S3Config{Endpoint: "localhost:9000"}); err == nil {
+		t.Error("expected error without bucket")
+	}
+}
+
+func newTestS3(t *testing.T) *S3 {
+	t.Helper()
+	ctx := context.Background()
+
+	cfg := S3Config{
+		Endpoint:  os.Getenv("S3_TEST_ENDPOINT"),
+		Bucket:    fmt.Sprintf("storage-test-%d", time.Now().UnixNano()),
+		AccessKey: os.Getenv("S3_TEST_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_TEST_SECRET_KEY"),
+		// С явным регионом клиент не запрашивает расположение бакета
+		Region: "us-east-1",
+	}
+	if cfg.Endpoint == "" {
+		srv := httptest.NewServer(newFakeS3())
+		t.Cleanup(srv.Close)
+		cfg.Endpoint = strings.TrimPrefix(srv.URL, "http://")
+		cfg.AccessKey, cfg.SecretKey = "test", "test-secret"
+	}
+
+	s, err := NewS3(ctx, cfg)
+	if err != nil {
+		t.Fatalf("NewS3: %v", err)
+	}
+	t.Cleanup(func() {
+		for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Recursive: true}) {
+			if info.Err == nil {
+				s.client.RemoveObject(ctx, s.bucket, info.Key, minio.RemoveObjectOptions{})
+			}
+		}
+		if err := s.client.RemoveBucket(ctx, s.bucket); err != nil {
+			t.Errorf("remove bucket: %v", err)
+		}
+	})
+	return s
+}
+
+// fakeS3 - S3-совместимый сервер в памяти. Понимает только запросы, которые делает S3,
+// без проверки подписи и с адресацией бакета в пути
+type fakeS3 struct {
+	mu      sync.Mutex
+	buckets map[string]map[string]fakeObject
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{buckets: make(map[string]map[string]fakeObject)}
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	objects, exists := f.buckets[bucket]
+
+	if key == "" {
+		switch r.Method {
+		case http.MethodHead:
+			if !exists {
+				w.WriteHeader(http.StatusNotFound)
+			}
+		case http.MethodPut:
+			f.buckets[bucket] = make(map[string]fakeObject)
+		case http.MethodDelete:
+			delete(f.buckets, bucket)
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodGet:
+			if !exists {
+				s3Error(w, http.StatusNotFound, "NoSuchBucket", bucket, "")
+				return
+			}
+			f.list(w, bucket, objects)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+
+	if !exists {
+		s3Error(w, http.StatusNotFound, "NoSuchBucket", bucket, key)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := readPayload(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		objects[key] = fakeObject{
+			data:        data,
+			contentType: r.Header.Get("Content-Type"),
+			modTime:     time.Now().UTC().Truncate(time.Second),
+		}
+		w.Header().Set("ETag", fakeETag(key))
+	case http.MethodGet, http.MethodHead:
+		obj, ok := objects[key]
+		if !ok {
+			if r.Method == http.MethodHead {
+				// Ответ на HEAD без тела: клиент сам определяет NoSuchKey по коду
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			s3Error(w, http.StatusNotFound, "NoSuchKey", bucket, key)
+			return
+		}
+		w.Header().Set("ETag", fakeETag(key))
+		w.Header().Set("Content-Type", obj.contentType)
+		// ServeContent отвечает и на запросы Range, которыми клиент читает после Seek
+		http.ServeContent(w, r, key, obj.modTime, bytes.NewReader(obj.data))
+	case http.MethodDelete:
+		delete(objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// list отвечает на ListObjectsV2 одной страницей
+func (f *fakeS3) list(w http.ResponseWriter, bucket string, objects map[string]fakeObject) {
+	type content struct {
+		Key          string
+		LastModified string
+		ETag         string
+		Size         int64
+		StorageClass string
+	}
+	result := struct {
+		XMLName     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+		Name        string
+		KeyCount    int
+		MaxKeys     int
+		IsTruncated bool
+		Contents    []content
+	}{Name: bucket, KeyCount: len(objects), MaxKeys: 1000}
+
+	for key, obj := range objects {
+		result.Contents = append(result.Contents, content{
+			Key:          key,
+			LastModified: obj.modTime.Format("2006-01-02T15:04:05.000Z"),
+			ETag:         fakeETag(key),
+			Size:         int64(len(obj.data)),
+			StorageClass: "STANDARD",
+		})
+	}
+	sort.Slice(result.Contents, func(i, j int) bool {
+		return result.Contents[i].Key < result.Contents[j].Key
+	})
+
+	w.Header().Set("Content-Type", "application/xml")
+	xml.NewEncoder(w).Encode(result)
+}
+
+// readPayload читает тело PUT. Без TLS клиент подписывает тело по частям
+// (aws-chunked): каждая часть предваряется строкой "<размер hex>;chunk-signature=...".
+func readPayload(r *http.Request) ([]byte, error) {
+	body := bufio.NewReader(r.Body)
+	if !strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-") {
+		return io.ReadAll(body)
+	}
+
+	var data []byte
+	for {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _, _ := strings.Cut(strings.TrimSpace(line), ";")
+		n, err := strconv.ParseInt(size, 16, 64)
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return data, nil
+		}
+		chunk := make([]byte, n+2)
+		if _, err := io.ReadFull(body, chunk); err != nil {
+			return nil, err
+		}
+		data = append(data, chunk[:n]...)
+	}
+}
+
+func s3Error(w http.ResponseWriter, status int, code, bucket, key string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	xml.NewEncoder(w).Encode(struct {
+		XMLName    xml.Name `xml:"Error"`
+		Code       string
+		Message    string
+		BucketName string
+		Key        string
+	}{Code: code, Message: code, BucketName: bucket, Key: key})
+}
+
+func fakeETag(key string) string {
+	return fmt.Sprintf("%q", fmt.Sprintf("%x", key))
+}
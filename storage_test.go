@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+// testStorage проверяет поведение, общее для всех реализаций Storage
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+
+	t.Run("put and get", func(t *testing.T) {
+		if err := s.Put(ctx, "get.jpg", []byte("first"), "image/jpeg"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		// Повторная запись перезаписывает объект
+		if err := s.Put(ctx, "get.jpg", []byte("second"), "image/jpeg"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+
+		r, obj, err := s.Get(ctx, "get.jpg")
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		defer r.Close()
+		data, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		if string(data) != "second" {
+			t.Errorf("data = %q, want %q", data, "second")
+		}
+		if obj.Key != "get.jpg" || obj.Size != 6 || obj.ContentType != "image/jpeg" {
+			t.Errorf("object = %+v", obj)
+		}
+
+		// Отдача файлов по HTTP использует Seek
+		if _, err := r.Seek(1, io.SeekStart); err != nil {
+			t.Fatalf("Seek: %v", err)
+		}
+		data, err = io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("read after seek: %v", err)
+		}
+		if string(data) != "econd" {
+			t.Errorf("data after seek = %q, want %q", data, "econd")
+		}
+	})
+
+	t.Run("stat", func(t *testing.T) {
+		if err := s.Put(ctx, "stat.webp", []byte("webp"), "image/webp"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		obj, err := s.Stat(ctx, "stat.webp")
+		if err != nil {
+			t.Fatalf("Stat: %v", err)
+		}
+		if obj.Key != "stat.webp" || obj.Size != 4 || obj.ModTime.IsZero() {
+			t.Errorf("object = %+v", obj)
+		}
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		if _, err := s.Stat(ctx, "missing.jpg"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat: err = %v, want ErrNotFound", err)
+		}
+		if _, _, err := s.Get(ctx, "missing.jpg"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Get: err = %v, want ErrNotFound", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		if err := s.Put(ctx, "delete.jpg", []byte("x"), "image/jpeg"); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+		if err := s.Delete(ctx, "delete.jpg"); err != nil {
+			t.Fatalf("Delete: %v", err)
+		}
+		if _, err := s.Stat(ctx, "delete.jpg"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Stat after Delete: err = %v, want ErrNotFound", err)
+		}
+		// Отсутствие объекта ошибкой не считается
+		if err := s.Delete(ctx, "delete.jpg"); err != nil {
+			t.Errorf("second Delete: %v", err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		var keys []string
+		err := s.List(ctx, func(obj Object) error {
+			keys = append(keys, obj.Key)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("List: %v", err)
+		}
+		slices.Sort(keys)
+		if want := []string{"get.jpg", "stat.webp"}; !slices.Equal(keys, want) {
+			t.Errorf("keys = %v, want %v", keys, want)
+		}
+
+		// Ошибка fn прерывает обход и возвращается как есть
+		stop := errors.New("stop")
+		calls := 0
+		err = s.List(ctx, func(obj Object) error {
+			calls++
+			return stop
+		})
+		if !errors.Is(err, stop) || calls != 1 {
+			t.Errorf("List with failing fn: err = %v, calls = %d", err, calls)
+		}
+	})
+
+	t.Run("invalid keys", func(t *testing.T) {
+		for _, key := range []string{"", "../escape.jpg", "dir/file.jpg", ".hidden.jpg", ".upload-123"} {
+			if err := s.Put(ctx, key, []byte("x"), "image/jpeg"); err == nil {
+				t.Errorf("Put(%q): expected error", key)
+			}
+			if _, err := s.Stat(ctx, key); err == nil {
+				t.Errorf("Stat(%q): expected error", key)
+			}
+			if _, _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+				t.Errorf("Get(%q): err = %v, want ErrNotFound", key, err)
+			}
+			if err := s.Delete(ctx, key); err == nil {
+				t.Errorf("Delete(%q): expected error", key)
+			}
+		}
+	})
+}
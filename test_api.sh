@@ -2,7 +2,7 @@
 
 # Базовый URL API
 BASE_URL="http://localhost:8080"
-API_KEY="your-secret-key"
+ACCESS_TOKEN=""
 
 # Функция для отправки запросов с авторизацией
 api_request() {
@@ -12,33 +12,182 @@ api_request() {
     
     curl -s -X $method \
         -H "Content-Type: application/json" \
-        -H "Key: $API_KEY" \
+        -H "Authorization: Bearer $ACCESS_TOKEN" \
         -d "$data" \
         "$BASE_URL$endpoint"
 }
 
-# Тест 1: Создание пользователя
-echo "=== Тест 1: Создание пользователя ==="
-USER_DATA='{"name": "John Doe", "email": "john@example.com"}'
-response=$(api_request POST "/users" "$USER_DATA")
+# Тест 1: Регистрация пользователя
+echo "=== Тест 1: Регистрация пользователя ==="
+USER_DATA='{"name": "John Doe", "email": "john@example.com", "password": "secret-password"}'
+response=$(api_request POST "/auth/register" "$USER_DATA")
 echo "Response: $response"
 
 # Извлекаем ID пользователя
 USER_ID=$(echo $response | grep -o '"id":[0-9]*' | cut -d: -f2)
 echo "Created user ID: $USER_ID"
 
-# Тест 2: Создание объявления (нужен реальный файл)
-echo -e "\n=== Тест 2: Создание объявления ==="
+# Тест 2: Вход
+echo -e "\n=== Тест 2: Вход ==="
+LOGIN_DATA='{"email": "john@example.com", "password": "secret-password"}'
+response=$(api_request POST "/auth/login" "$LOGIN_DATA")
+echo "Response: $response"
+
+ACCESS_TOKEN=$(echo $response | grep -o '"access_token":"[^"]*' | cut -d'"' -f4)
+REFRESH_TOKEN=$(echo $response | grep -o '"refresh_token":"[^"]*' | cut -d'"' -f4)
+
+# Тест 3: Обновление токенов; старый refresh-токен после этого недействителен
+echo -e "\n=== Тест 3: Обновление токенов ==="
+response=$(api_request POST "/auth/refresh" "{\"refresh_token\": \"$REFRESH_TOKEN\"}")
+echo "Response: $response"
+ACCESS_TOKEN=$(echo $response | grep -o '"access_token":"[^"]*' | cut -d'"' -f4)
+
+response=$(api_request GET "/auth/me" "")
+echo "Current user: $response"
+
+# Тест 4: Профиль пользователя
+echo -e "\n=== Тест 4: Профиль пользователя ==="
+response=$(api_request GET "/users/$USER_ID" "")
+echo "Profile: $response"
+response=$(api_request PATCH "/users/$USER_ID" '{"name": "John Smith"}')
+echo "Updated profile: $response"
+response=$(api_request GET "/users/$USER_ID/ads?sort=price&order=asc" "")
+echo "User ads: $response"
+response=$(api_request GET "/users?q=john" "")
+echo "User list without users:manage (expect forbidden): $response"
+
+# Тест 5: Создание объявления (нужен реальный файл)
+echo -e "\n=== Тест 5: Создание объявления ==="
 echo "Note: This requires a real image file. Use curl with -F flag in practice."
 
-# Тест 3: Получение всех объявлений
-echo -e "\n=== Тест 3: Получение всех объявлений ==="
+# Тест 6: Получение всех объявлений
+echo -e "\n=== Тест 6: Получение всех объявлений ==="
 response=$(api_request GET "/ads" "")
 echo "Response: $response"
 
-# Тест 4: Удаление пользователя
-echo -e "\n=== Тест 4: Удаление пользователя ==="
+response=$(curl -s "$BASE_URL/public/ads")
+echo "Public catalog without token: $response"
+
+# Тест 7: API-ключ только для чтения
+echo -e "\n=== Тест 7: API-ключ только для чтения ==="
+response=$(api_request POST "/api-keys" '{"name": "test key", "scopes": ["read-only"]}')
+echo "Response: $response"
+API_KEY=$(echo $response | grep -o '"key":"[^"]*' | cut -d'"' -f4)
+API_KEY_ID=$(echo $response | grep -o '"id":[0-9]*' | head -1 | cut -d: -f2)
+
+response=$(curl -s -H "X-API-Key: $API_KEY" "$BASE_URL/ads")
+echo "GET with key: $response"
+response=$(curl -s -X PATCH -H "X-API-Key: $API_KEY" "$BASE_URL/ads/1/toggle")
+echo "PATCH with read-only key (expect forbidden): $response"
+
+response=$(api_request GET "/api-keys/$API_KEY_ID/audit" "")
+echo "Audit log: $response"
+response=$(api_request DELETE "/api-keys/$API_KEY_ID" "")
+echo "Revoke: $response"
+response=$(curl -s -H "X-API-Key: $API_KEY" "$BASE_URL/ads")
+echo "GET with revoked key (expect unauthorized): $response"
+
+# Тест 8: Выход; access-токен сессии больше не принимается
+echo -e "\n=== Тест 8: Выход ==="
+response=$(api_request POST "/auth/logout" "")
+echo "Response: $response"
+response=$(api_request GET "/ads" "")
+echo "After logout (expect unauthorized): $response"
+
+# Тест 9: Удаление пользователя без права users:manage
+echo -e "\n=== Тест 9: Удаление пользователя без прав ==="
+response=$(api_request POST "/auth/login" "$LOGIN_DATA")
+ACCESS_TOKEN=$(echo $response | grep -o '"access_token":"[^"]*' | cut -d'"' -f4)
 response=$(api_request DELETE "/users/$USER_ID" "")
+echo "Response (expect forbidden): $response"
+
+# Тест 10: Удаление пользователя администратором; роль выдается служебной командой
+echo -e "\n=== Тест 10: Удаление пользователя администратором ==="
+go run ./cmd/server grant-role john@example.com admin
+response=$(api_request GET "/admin/users/$USER_ID/roles" "")
+echo "Roles: $response"
+response=$(api_request POST "/users" '{"name": "Jane Doe", "email": "jane@example.com", "password": "secret-password"}')
+OTHER_ID=$(echo $response | grep -o '"id":[0-9]*' | head -1 | cut -d: -f2)
+response=$(api_request DELETE "/users/$OTHER_ID" "")
 echo "Response: $response"
 
+# Тест 11: Восстановление удаленного пользователя
+echo -e "\n=== Тест 11: Восстановление пользователя ==="
+response=$(api_request GET "/users?deleted=true&q=jane" "")
+echo "Deleted users: $response"
+response=$(api_request POST "/users/$OTHER_ID/restore" "")
+echo "Restored: $response"
+
+# Тест 12: Политики удаления пользователя с объявлениями
+echo -e "\n=== Тест 12: Политики удаления ==="
+response=$(api_request DELETE "/users/$OTHER_ID?policy=unknown" "")
+echo "Unknown policy (expect bad request): $response"
+response=$(api_request DELETE "/users/$OTHER_ID?policy=reassign" "")
+echo "Reassign without target (expect bad request): $response"
+response=$(api_request DELETE "/users/$OTHER_ID?policy=block" "")
+echo "Block without ads: $response"
+response=$(api_request POST "/users/$OTHER_ID/restore" "")
+response=$(api_request DELETE "/users/$OTHER_ID?policy=anonymize" "")
+echo "Anonymize: $response"
+response=$(api_request POST "/users/$OTHER_ID/restore" "")
+echo "Restore anonymized (expect conflict): $response"
+response=$(api_request DELETE "/users/$USER_ID" "")
+echo "Delete the last administrator (expect conflict): $response"
+
+# Тест 13: Выгрузка и стирание данных выполняются в фоне (USER_DATA_JOBS_INTERVAL)
+echo -e "\n=== Тест 13: Выгрузка и стирание данных ==="
+response=$(api_request POST "/users/$USER_ID/export" "")
+echo "Export requested: $response"
+JOB_ID=$(echo $response | grep -o '"id":[0-9]*' | head -1 | cut -d: -f2)
+sleep 15
+response=$(api_request GET "/users/$USER_ID/export" "")
+echo "Latest export: $response"
+curl -s -o /tmp/user-export.zip -w "Download status: %{http_code}\n" \
+    -H "Authorization: Bearer $ACCESS_TOKEN" \
+    "$BASE_URL/users/$USER_ID/data-jobs/$JOB_ID/download"
+unzip -l /tmp/user-export.zip
+response=$(api_request POST "/users/$OTHER_ID/erasure" "")
+echo "Erasure of a deleted user: $response"
+sleep 15
+response=$(api_request GET "/users/$OTHER_ID/data-jobs" "")
+echo "Data jobs: $response"
+
+# Тест 14: Управление категориями (у администратора есть categories:manage)
+echo -e "\n=== Тест 14: Категории ==="
+response=$(curl -s "$BASE_URL/categories")
+echo "Categories without token: $response"
+response=$(api_request POST "/categories" '{"name": "Books", "attributes": [{"name": "format", "type": "enum", "enum": ["paperback", "hardcover"], "required": true}]}')
+echo "Created: $response"
+CATEGORY_ID=$(echo $response | grep -o '"id":[0-9]*' | head -1 | cut -d: -f2)
+response=$(api_request PUT "/categories/$CATEGORY_ID" '{"name": "Books", "attributes": [{"name": "format", "type": "enum", "enum": ["paperback", "hardcover", "ebook"], "required": true}, {"name": "pages", "type": "int"}]}')
+echo "Updated: $response"
+response=$(api_request POST "/categories" '{"name": "Books"}')
+echo "Duplicate name (expect conflict): $response"
+response=$(api_request POST "/categories" "{\"name\": \"Comics\", \"parent_id\": $CATEGORY_ID}")
+echo "Subcategory: $response"
+CHILD_ID=$(echo $response | grep -o '"id":[0-9]*' | head -1 | cut -d: -f2)
+response=$(curl -s "$BASE_URL/categories/tree")
+echo "Tree: $response"
+response=$(api_request PUT "/categories/$CATEGORY_ID" "{\"name\": \"Books\", \"parent_id\": $CHILD_ID}")
+echo "Move under own descendant (expect conflict): $response"
+response=$(api_request DELETE "/categories/$CATEGORY_ID" "")
+echo "Delete with subcategories (expect conflict): $response"
+response=$(api_request DELETE "/categories/$CHILD_ID" "")
+response=$(api_request DELETE "/categories/$CATEGORY_ID" "")
+echo "Deleted: $response"
+
+# Тест 15: Атрибуты категорий и фильтры по атрибутам объявлений
+echo -e "\n=== Тест 15: Атрибуты ==="
+response=$(api_request POST "/categories" '{"name": "Laptops", "attributes": [{"name": "color", "type": "string", "unit": "GB"}]}')
+echo "Unit on a string attribute (expect bad request): $response"
+response=$(api_request POST "/categories" '{"name": "Laptops", "attributes": [{"name": "ram_gb", "type": "int", "required": true, "unit": "GB"}, {"name": "brand", "type": "enum", "enum": ["apple", "lenovo"]}]}')
+echo "Created: $response"
+CATEGORY_ID=$(echo $response | grep -o '"id":[0-9]*' | head -1 | cut -d: -f2)
+echo "Create an ad with: curl -F images=@photo.jpg -F category_id=$CATEGORY_ID -F 'attributes={\"ram_gb\": 16, \"brand\": \"lenovo\"}' ..."
+response=$(api_request GET "/ads?category_id=$CATEGORY_ID&attr.brand=lenovo&attr.ram_gb%3E=8" "")
+echo "Filtered by attributes: $response"
+response=$(curl -s "$BASE_URL/public/ads?attr.ram_gb%3E=many")
+echo "Non-numeric comparison (expect bad request): $response"
+response=$(api_request DELETE "/categories/$CATEGORY_ID" "")
+
 echo -e "\n=== Тестирование завершено ==="
\ No newline at end of file
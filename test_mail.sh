@@ -0,0 +1,114 @@
+#!/bin/bash
+
+# Интеграционный тест писем на локальном MailHog.
+# Требуется: docker, запущенный PostgreSQL из main.go, curl, python3.
+# Скрипт поднимает MailHog, запускает сервер с MAIL_BACKEND=smtp и проходит
+# подтверждение email и сброс пароля по письмам, полученным MailHog.
+
+set -e
+
+BASE_URL="http://localhost:8080"
+MAILHOG_API="http://localhost:8025/api/v2"
+JWT_SECRET="${JWT_SECRET:-mail-test-secret-mail-test-secret-mail}"
+MAILHOG_CONTAINER="golang-test-mailhog"
+
+cleanup() {
+    [ -n "$SERVER_PID" ] && kill $SERVER_PID 2>/dev/null || true
+    docker rm -f $MAILHOG_CONTAINER >/dev/null 2>&1 || true
+}
+trap cleanup EXIT
+
+fail() {
+    echo "FAIL: $1"
+    exit 1
+}
+
+# Печатает раскодированное тело последнего письма на адрес $1 с темой $2
+last_mail() {
+    curl -s "$MAILHOG_API/search?kind=to&query=$1" | python3 -c '
+import email, json, sys
+from email.header import decode_header, make_header
+for item in json.load(sys.stdin)["items"]:
+    msg = email.message_from_string(item["Raw"]["Data"])
+    if str(make_header(decode_header(msg["Subject"]))) == sys.argv[1]:
+        print(msg.get_payload(decode=True).decode().replace("\r\n", "\n"))
+        break
+' "$2"
+}
+
+# Ждет письмо: сброс пароля отправляется в фоне
+wait_mail() {
+    for i in $(seq 1 20); do
+        body=$(last_mail "$1" "$2")
+        [ -n "$body" ] && echo "$body" && return
+        sleep 1
+    done
+}
+
+echo "=== Запуск MailHog ==="
+docker run -d --name $MAILHOG_CONTAINER -p 1025:1025 -p 8025:8025 mailhog/mailhog >/dev/null
+for i in $(seq 1 30); do
+    curl -sf "$MAILHOG_API/messages" >/dev/null && break
+    sleep 1
+done
+
+echo "=== Запуск сервера с отправкой через SMTP ==="
+MAIL_BACKEND=smtp \
+SMTP_HOST=localhost \
+SMTP_PORT=1025 \
+MAIL_FROM=no-reply@example.com \
+JWT_SECRET=$JWT_SECRET \
+go run ./cmd/server &
+SERVER_PID=$!
+for i in $(seq 1 60); do
+    curl -sf "$BASE_URL/health" >/dev/null && break
+    sleep 1
+done
+
+echo "=== Тест 1: Подтверждение email ==="
+EMAIL="mail-test-$$@example.com"
+curl -s -X POST "$BASE_URL/auth/register" -H "Content-Type: application/json" \
+    -d "{\"name\": \"Mail Test\", \"email\": \"$EMAIL\", \"password\": \"mail-test-password\"}" >/dev/null
+
+body=$(wait_mail "$EMAIL" "Подтверждение email")
+echo "$body"
+TOKEN=$(echo "$body" | grep -o 'token=[A-Za-z0-9_-]*' | head -1 | cut -d= -f2)
+[ -n "$TOKEN" ] || fail "verification email was not received"
+
+response=$(curl -s "$BASE_URL/auth/verify-email?token=$TOKEN")
+echo "$response" | grep -q '"success"' || fail "email was not verified: $response"
+response=$(curl -s "$BASE_URL/auth/verify-email?token=$TOKEN")
+echo "$response" | grep -q 'invalid or expired token' || fail "verification token was accepted twice: $response"
+
+ACCESS_TOKEN=$(curl -s -X POST "$BASE_URL/auth/login" -H "Content-Type: application/json" \
+    -d "{\"email\": \"$EMAIL\", \"password\": \"mail-test-password\"}" | grep -o '"access_token":"[^"]*' | cut -d'"' -f4)
+[ -n "$ACCESS_TOKEN" ] || fail "login failed"
+response=$(curl -s "$BASE_URL/auth/me" -H "Authorization: Bearer $ACCESS_TOKEN")
+echo "$response" | grep -q '"email_verified_at"' || fail "email_verified_at is missing: $response"
+echo "OK: email verified"
+
+echo "=== Тест 2: Сброс пароля ==="
+curl -s -X POST "$BASE_URL/auth/password-reset" -H "Content-Type: application/json" \
+    -d "{\"email\": \"$EMAIL\"}" >/dev/null
+
+body=$(wait_mail "$EMAIL" "Сброс пароля")
+echo "$body"
+# Код - единственная строка письма из символов base64url
+CODE=$(echo "$body" | grep -x '[A-Za-z0-9_-]\{43\}' | head -1)
+[ -n "$CODE" ] || fail "password reset email was not received"
+
+response=$(curl -s -X POST "$BASE_URL/auth/password-reset/confirm" -H "Content-Type: application/json" \
+    -d "{\"token\": \"$CODE\", \"password\": \"new-mail-test-password\"}")
+echo "$response" | grep -q '"success"' || fail "password was not reset: $response"
+response=$(curl -s -X POST "$BASE_URL/auth/password-reset/confirm" -H "Content-Type: application/json" \
+    -d "{\"token\": \"$CODE\", \"password\": \"another-password\"}")
+echo "$response" | grep -q 'invalid or expired token' || fail "reset code was accepted twice: $response"
+
+response=$(curl -s -o /dev/null -w "%{http_code}" "$BASE_URL/auth/me" -H "Authorization: Bearer $ACCESS_TOKEN")
+[ "$response" = "401" ] || fail "session survived password reset"
+response=$(curl -s -X POST "$BASE_URL/auth/login" -H "Content-Type: application/json" \
+    -d "{\"email\": \"$EMAIL\", \"password\": \"new-mail-test-password\"}")
+echo "$response" | grep -q '"access_token"' || fail "login with new password failed: $response"
+echo "OK: password reset, sessions revoked"
+
+echo -e "\n=== Тестирование завершено ==="
@@ -0,0 +1,126 @@
+#!/bin/bash
+
+# Интеграционный тест S3-хранилища изображений на локальном MinIO.
+# Требуется: docker, запущенный PostgreSQL из main.go, curl.
+# Скрипт поднимает MinIO, запускает сервер с STORAGE_BACKEND=s3,
+# создает объявление с изображением и проверяет, что файлы появились
+# в бакете, остались после мягкого удаления объявления и были удалены
+# при окончательной очистке (purge-deleted).
+
+set -e
+
+BASE_URL="http://localhost:8080"
+JWT_SECRET="${JWT_SECRET:-s3-test-secret-s3-test-secret-s3-test}"
+MINIO_CONTAINER="golang-test-minio"
+BUCKET="ad-images-test"
+
+cleanup() {
+    [ -n "$SERVER_PID" ] && kill $SERVER_PID 2>/dev/null || true
+    docker rm -f $MINIO_CONTAINER >/dev/null 2>&1 || true
+    rm -f /tmp/test_image.png
+}
+trap cleanup EXIT
+
+fail() {
+    echo "FAIL: $1"
+    exit 1
+}
+
+# Проверяет наличие объекта в бакете через mc внутри контейнера MinIO
+object_exists() {
+    docker exec $MINIO_CONTAINER mc stat "local/$BUCKET/$1" >/dev/null 2>&1
+}
+
+# Копии в WebP создаются только в сборке с тегом webp:
+# GOFLAGS=-tags=webp ./test_storage_s3.sh
+webp_enabled() {
+    [[ "$GOFLAGS" == *webp* ]]
+}
+
+echo "=== Запуск MinIO ==="
+docker run -d --name $MINIO_CONTAINER -p 9000:9000 \
+    -e MINIO_ROOT_USER=minioadmin -e MINIO_ROOT_PASSWORD=minioadmin \
+    minio/minio server /data >/dev/null
+for i in $(seq 1 30); do
+    curl -sf http://localhost:9000/minio/health/live >/dev/null && break
+    sleep 1
+done
+docker exec $MINIO_CONTAINER mc alias set local http://localhost:9000 minioadmin minioadmin >/dev/null
+
+echo "=== Запуск сервера с S3-хранилищем ==="
+# Служебные команды ниже работают с тем же хранилищем, что и сервер
+export STORAGE_BACKEND=s3
+export S3_ENDPOINT=localhost:9000
+export S3_BUCKET=$BUCKET
+export S3_ACCESS_KEY=minioadmin
+export S3_SECRET_KEY=minioadmin
+export JWT_SECRET
+go run ./cmd/server &
+SERVER_PID=$!
+for i in $(seq 1 60); do
+    curl -sf "$BASE_URL/health" >/dev/null && break
+    sleep 1
+done
+
+echo "=== Тест 1: Создание объявления с изображением ==="
+# Минимальный PNG 1x1
+echo 'iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNk+M9QDwADhgGAWjR9awAAAABJRU5ErkJggg==' | base64 -d > /tmp/test_image.png
+
+EMAIL="s3-test-$$@example.com"
+USER_ID=$(curl -s -X POST "$BASE_URL/auth/register" -H "Content-Type: application/json" \
+    -d "{\"name\": \"S3 Test\", \"email\": \"$EMAIL\", \"password\": \"s3-test-password\"}" | grep -o '"id":[0-9]*' | head -1 | cut -d: -f2)
+[ -n "$USER_ID" ] || fail "user was not created"
+
+ACCESS_TOKEN=$(curl -s -X POST "$BASE_URL/auth/login" -H "Content-Type: application/json" \
+    -d "{\"email\": \"$EMAIL\", \"password\": \"s3-test-password\"}" | grep -o '"access_token":"[^"]*' | cut -d'"' -f4)
+[ -n "$ACCESS_TOKEN" ] || fail "login failed"
+
+response=$(curl -s -X POST "$BASE_URL/ads" -H "Authorization: Bearer $ACCESS_TOKEN" \
+    -F "images=@/tmp/test_image.png" -F "user_id=$USER_ID" -F "category_id=1" \
+    -F "title=S3 test" -F "description=S3 storage test" -F "price=10")
+echo "Response: $response"
+AD_ID=$(echo $response | grep -o '"id":[0-9]*' | head -1 | cut -d: -f2)
+# Имя файла - последний сегмент ссылки на оригинал
+FILENAME=$(echo $response | grep -o '"url":"[^"?]*' | head -1 | sed 's|.*/||')
+[ -n "$FILENAME" ] || fail "ad was not created"
+
+object_exists "$FILENAME" || fail "original $FILENAME is missing in bucket"
+object_exists "${FILENAME%.*}_thumb.jpg" || fail "thumbnail is missing in bucket"
+if webp_enabled; then
+    object_exists "${FILENAME%.*}_thumb.webp" || fail "WebP thumbnail is missing in bucket"
+fi
+echo "OK: original and variants stored in bucket"
+
+echo "=== Тест 2: Мягкое удаление объявления ==="
+# Удаленное объявление можно восстановить, поэтому файлы остаются в бакете
+curl -s -X DELETE "$BASE_URL/ads/$AD_ID" -H "Authorization: Bearer $ACCESS_TOKEN" >/dev/null
+object_exists "$FILENAME" || fail "original $FILENAME was removed by soft delete"
+object_exists "${FILENAME%.*}_thumb.jpg" || fail "thumbnail was removed by soft delete"
+if webp_enabled; then
+    object_exists "${FILENAME%.*}_thumb.webp" || fail "WebP thumbnail was removed by soft delete"
+fi
+echo "OK: files kept after soft delete"
+
+echo "=== Тест 3: Окончательная очистка ==="
+go run ./cmd/server purge-deleted -retention=0s >/dev/null
+object_exists "$FILENAME" && fail "original $FILENAME was not removed from bucket"
+object_exists "${FILENAME%.*}_thumb.jpg" && fail "thumbnail was not removed from bucket"
+if webp_enabled; then
+    object_exists "${FILENAME%.*}_thumb.webp" && fail "WebP thumbnail was not removed from bucket"
+fi
+echo "OK: files removed from bucket"
+
+echo "=== Удаление тестового пользователя администратором ==="
+# Удалять пользователей может только администратор; роль выдается служебной командой
+ADMIN_EMAIL="s3-admin-$$@example.com"
+curl -s -X POST "$BASE_URL/auth/register" -H "Content-Type: application/json" \
+    -d "{\"name\": \"S3 Admin\", \"email\": \"$ADMIN_EMAIL\", \"password\": \"s3-test-password\"}" >/dev/null
+go run ./cmd/server grant-role "$ADMIN_EMAIL" admin >/dev/null
+ADMIN_TOKEN=$(curl -s -X POST "$BASE_URL/auth/login" -H "Content-Type: application/json" \
+    -d "{\"email\": \"$ADMIN_EMAIL\", \"password\": \"s3-test-password\"}" | grep -o '"access_token":"[^"]*' | cut -d'"' -f4)
+[ -n "$ADMIN_TOKEN" ] || fail "admin login failed"
+status=$(curl -s -o /dev/null -w "%{http_code}" -X DELETE "$BASE_URL/users/$USER_ID" -H "Authorization: Bearer $ADMIN_TOKEN")
+[ "$status" = "200" ] || fail "user deletion returned $status"
+echo "OK: user deleted"
+
+echo -e "\n=== Тестирование завершено ==="
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+
+// Config - настройки выдачи токенов
+type Config struct {
+	Secret     []byte
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// Сроки действия одноразовых токенов из писем
+	EmailVerificationTTL time.Duration
+	PasswordResetTTL     time.Duration
+	// EmailInterval - минимальный интервал между письмами одного назначения
+	// на один адрес; 0 снимает ограничение
+	EmailInterval time.Duration
+}
+
+// ConfigFromEnv читает настройки из переменных окружения:
+//
+//	JWT_SECRET              ключ подписи access-токенов, обязателен
+//	ACCESS_TOKEN_TTL        срок жизни access-токена, по умолчанию 15m
+//	REFRESH_TOKEN_TTL       срок жизни refresh-токена, по умолчанию 720h
+//	EMAIL_VERIFICATION_TTL  срок действия ссылки подтверждения email, по умолчанию 48h
+//	PASSWORD_RESET_TTL      срок действия токена сброса пароля, по умолчанию 1h
+//	EMAIL_INTERVAL          минимальный интервал между письмами подтверждения
+//	                        или сброса пароля на один адрес, по умолчанию 1m
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Secret:               []byte(os.Getenv("JWT_SECRET")),
+		AccessTTL:            15 * time.Minute,
+		RefreshTTL:           30 * 24 * time.Hour,
+		EmailVerificationTTL: 48 * time.Hour,
+		PasswordResetTTL:     time.Hour,
+		EmailInterval:        time.Minute,
+	}
+	if len(cfg.Secret) < 32 {
+		return cfg, errors.New("JWT_SECRET must be at least 32 bytes")
+	}
+
+	if v := os.Getenv("ACCESS_TOKEN_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, errors.New("invalid ACCESS_TOKEN_TTL")
+		}
+		cfg.AccessTTL = d
+	}
+	if v := os.Getenv("REFRESH_TOKEN_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, errors.New("invalid REFRESH_TOKEN_TTL")
+		}
+		cfg.RefreshTTL = d
+	}
+	if v := os.Getenv("EMAIL_VERIFICATION_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, errors.New("invalid EMAIL_VERIFICATION_TTL")
+		}
+		cfg.EmailVerificationTTL = d
+	}
+	if v := os.Getenv("PASSWORD_RESET_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, errors.New("invalid PASSWORD_RESET_TTL")
+		}
+		cfg.PasswordResetTTL = d
+	}
+	if v := os.Getenv("EMAIL_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, errors.New("invalid EMAIL_INTERVAL")
+		}
+		cfg.EmailInterval = d
+	}
+	return cfg, nil
+}
+
+// Claims - содержимое access-токена. SessionID связывает токен с сессией,
+// поэтому после выхода токен перестает приниматься, не дожидаясь истечения.
+type Claims struct {
+	SessionID string `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// UserID возвращает ID пользователя из поля sub
+func (c *Claims) UserID() (int, error) {
+	return strconv.Atoi(c.Subject)
+}
+
+// Tokens выдает и проверяет токены
+type Tokens struct {
+	cfg Config
+}
+
+func NewTokens(cfg Config) *Tokens {
+	return &Tokens{cfg: cfg}
+}
+
+// AccessTTL возвращает срок жизни access-токена
+func (t *Tokens) AccessTTL() time.Duration {
+	return t.cfg.AccessTTL
+}
+
+// RefreshTTL возвращает срок жизни refresh-токена
+func (t *Tokens) RefreshTTL() time.Duration {
+	return t.cfg.RefreshTTL
+}
+
+// EmailVerificationTTL возвращает срок действия ссылки подтверждения email
+func (t *Tokens) EmailVerificationTTL() time.Duration {
+	return t.cfg.EmailVerificationTTL
+}
+
+// PasswordResetTTL возвращает срок действия токена сброса пароля
+func (t *Tokens) PasswordResetTTL() time.Duration {
+	return t.cfg.PasswordResetTTL
+}
+
+// EmailInterval возвращает минимальный интервал между письмами одного назначения на один адрес
+func (t *Tokens) EmailInterval() time.Duration {
+	return t.cfg.EmailInterval
+}
+
+// IssueAccess подписывает access-токен для сессии пользователя
+func (t *Tokens) IssueAccess(userID int, sessionID string) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(userID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(t.cfg.AccessTTL)),
+		},
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.cfg.Secret)
+}
+
+// ParseAccess проверяет подпись и срок действия access-токена
+func (t *Tokens) ParseAccess(token string) (*Claims, error) {
+	var claims Claims
+	_, err := jwt.ParseWithClaims(token, &claims, func(*jwt.Token) (interface{}, error) {
+		return t.cfg.Secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	if _, err := claims.UserID(); err != nil || claims.SessionID == "" {
+		return nil, ErrInvalidToken
+	}
+	return &claims, nil
+}
+
+// NewRefreshToken возвращает случайный refresh-токен и его хеш для хранения в базе.
+// Сам токен в базе не хранится.
+func NewRefreshToken() (token, hash string, err error) {
+	return newOpaqueToken()
+}
+
+// NewEmailToken возвращает одноразовый токен для ссылки из письма и его хеш
+func NewEmailToken() (token, hash string, err error) {
+	return newOpaqueToken()
+}
+
+func newOpaqueToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = base64.RawURLEncoding.EncodeToString(b)
+	return token, HashToken(token), nil
+}
+
+// HashToken возвращает SHA-256 токена в hex
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,250 @@
+package upload
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"log/slog"
+	"mime/multipart"
+	"net/http"
+
+	"golang-test/internal/imaging"
+	"golang-test/internal/storage"
+)
+
+// Config задает ограничения для загружаемых изображений
+type Config struct {
+	MaxFileSize int64
+	MaxWidth    int
+	MaxHeight   int
+	// MaxPixels защищает от "бомб декомпрессии": маленький файл с огромными
+	// размерами отклоняется до декодирования пикселей
+	MaxPixels int
+}
+
+func DefaultConfig() Config {
+	return Config{
+		MaxFileSize: 10 << 20,
+		MaxWidth:    8000,
+		MaxHeight:   8000,
+		MaxPixels:   40_000_000,
+	}
+}
+
+// Поддерживаемые форматы: MIME-тип по сигнатуре файла -> расширение сохраненного файла
+var allowedFormats = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+}
+
+// Коды ошибок проверки
+const (
+	CodeTooLarge          = "file_too_large"
+	CodeUnsupportedFormat = "unsupported_format"
+	CodeInvalidImage      = "invalid_image"
+	CodeDimensions        = "dimensions_too_large"
+	CodeTooManyPixels     = "too_many_pixels"
+)
+
+// ValidationError описывает, почему конкретный файл не прошел проверку
+type ValidationError struct {
+	File    string `json:"file"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ValidationErrors - ошибки проверки всех отклоненных файлов запроса
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	if len(e) == 0 {
+		return "validation failed"
+	}
+	return fmt.Sprintf("%s: %s", e[0].File, e[0].Message)
+}
+
+// Service проверяет загруженные изображения и сохраняет их в хранилище
+type Service struct {
+	cfg   Config
+	store storage.Storage
+}
+
+func NewService(cfg Config, store storage.Storage) *Service {
+	return &Service{cfg: cfg, store: store}
+}
+
+// checkedImage - файл, прошедший проверку и очищенный от метаданных
+type checkedImage struct {
+	data        []byte
+	ext         string
+	contentType string
+	img         image.Image
+}
+
+// SaveImages проверяет файлы и сохраняет их вместе с уменьшенными копиями.
+// Имя файла - SHA-256 очищенного содержимого, поэтому одинаковые изображения
+// хранятся один раз; учет ссылок ведет репозиторий объявлений.
+// Файлы обрабатываются по одному, чтобы в памяти не держать несколько
+// декодированных изображений. Если хотя бы один файл не прошел проверку,
+// возвращается ValidationErrors со списком всех отклоненных файлов, а файлы,
+// созданные этим вызовом, удаляются.
+func (s *Service) SaveImages(ctx context.Context, files []*multipart.FileHeader) ([]string, error) {
+	filenames := make([]string, 0, len(files))
+	// created - файлы, которых не было в хранилище до этого вызова
+	var created []string
+	var verrs ValidationErrors
+
+	for _, file := range files {
+		ci, verr, err := s.check(file)
+		if err != nil {
+			s.remove(ctx, created)
+			return nil, err
+		}
+		if verr != nil {
+			verrs = append(verrs, *verr)
+			continue
+		}
+		if len(verrs) > 0 {
+			// Остальные файлы только проверяем, чтобы вернуть все ошибки сразу
+			continue
+		}
+
+		sum := sha256.Sum256(ci.data)
+		filename := hex.EncodeToString(sum[:]) + ci.ext
+		filenames = append(filenames, filename)
+
+		_, err = s.store.Stat(ctx, filename)
+		exists := err == nil
+		if err != nil && !errors.Is(err, storage.ErrNotFound) {
+			s.remove(ctx, created)
+			return nil, err
+		}
+		if !exists {
+			created = append(created, filename)
+		}
+
+		// Существующий файл тоже перезаписываем: содержимое то же, а новое время
+		// изменения защищает его от сборщика мусора, пока объявление не сохранено
+		if err := s.store.Put(ctx, filename, ci.data, ci.contentType); err != nil {
+			s.remove(ctx, created)
+			return nil, err
+		}
+
+		if exists && s.hasVariants(ctx, filename) {
+			continue
+		}
+		if err := s.WriteVariants(ctx, filename, ci.img); err != nil {
+			s.remove(ctx, created)
+			return nil, err
+		}
+	}
+
+	if len(verrs) > 0 {
+		s.remove(ctx, created)
+		return nil, verrs
+	}
+
+	return filenames, nil
+}
+
+// hasVariants сообщает, сохранены ли все уменьшенные копии изображения
+func (s *Service) hasVariants(ctx context.Context, filename string) bool {
+	for _, name := range imaging.VariantFilenames(filename) {
+		if _, err := s.store.Stat(ctx, name); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteVariants сохраняет в хранилище все уменьшенные копии изображения во всех форматах (imaging.Formats)
+func (s *Service) WriteVariants(ctx context.Context, filename string, img image.Image) error {
+	for _, v := range imaging.Variants {
+		for _, f := range imaging.Formats {
+			data, err := imaging.RenderVariant(img, v, f)
+			if err != nil {
+				return err
+			}
+			if err := s.store.Put(ctx, imaging.VariantFilename(filename, v.Name, f), data, f.ContentType); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// remove удаляет файлы и их варианты, созданные неудавшимся вызовом SaveImages
+func (s *Service) remove(ctx context.Context, filenames []string) {
+	// Очистка должна завершиться, даже если клиент уже отключился
+	ctx = context.WithoutCancel(ctx)
+	for _, filename := range filenames {
+		for _, name := range imaging.Filenames(filename) {
+			if err := s.store.Delete(ctx, name); err != nil {
+				slog.Error("failed to remove image file", "error", err, "filename", name)
+			}
+		}
+	}
+}
+
+// check определяет формат по содержимому файла и декодирует его.
+// Ошибка err возвращается только при проблемах чтения, не связанных с содержимым.
+func (s *Service) check(file *multipart.FileHeader) (checkedImage, *ValidationError, error) {
+	invalid := func(code, format string, args ...interface{}) (checkedImage, *ValidationError, error) {
+		return checkedImage{}, &ValidationError{File: file.Filename, Code: code, Message: fmt.Sprintf(format, args...)}, nil
+	}
+
+	if file.Size > s.cfg.MaxFileSize {
+		return invalid(CodeTooLarge, "file exceeds %d bytes", s.cfg.MaxFileSize)
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return checkedImage{}, nil, err
+	}
+	defer f.Close()
+
+	// Читаем не больше лимита + 1 байт: размер из заголовка multipart может не совпадать с телом
+	data, err := io.ReadAll(io.LimitReader(f, s.cfg.MaxFileSize+1))
+	if err != nil {
+		return checkedImage{}, nil, err
+	}
+	if int64(len(data)) > s.cfg.MaxFileSize {
+		return invalid(CodeTooLarge, "file exceeds %d bytes", s.cfg.MaxFileSize)
+	}
+
+	contentType := http.DetectContentType(data)
+	ext, ok := allowedFormats[contentType]
+	if !ok {
+		return invalid(CodeUnsupportedFormat, "only png and jpeg images are allowed")
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return invalid(CodeInvalidImage, "file is not a valid image")
+	}
+	if cfg.Width > s.cfg.MaxWidth || cfg.Height > s.cfg.MaxHeight {
+		return invalid(CodeDimensions, "image must be at most %dx%d pixels", s.cfg.MaxWidth, s.cfg.MaxHeight)
+	}
+	if cfg.Width*cfg.Height > s.cfg.MaxPixels {
+		return invalid(CodeTooManyPixels, "image must have at most %d pixels", s.cfg.MaxPixels)
+	}
+
+	// Перекодируем изображение: это подтверждает, что файл декодируется целиком,
+	// поворачивает пиксели по EXIF и удаляет метаданные (в том числе GPS)
+	clean, img, err := imaging.Sanitize(data)
+	if err != nil {
+		if errors.Is(err, imaging.ErrInvalidImage) {
+			return invalid(CodeInvalidImage, "file is not a valid image")
+		}
+		return checkedImage{}, nil, err
+	}
+
+	return checkedImage{data: clean, ext: ext, contentType: contentType, img: img}, nil, nil
+}
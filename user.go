@@ -1,10 +1,24 @@
-package models
-
-import "time"
-
-type User struct {
-	ID        int       `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-}
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+type User struct {
+	ID          int      `json:"id"`
+	Name        string   `json:"name"`
+	Email       string   `json:"email"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions,omitempty"`
+	// EmailVerifiedAt пуст, пока email не подтвержден по ссылке из письма
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	// DeletedAt заполнен только у удаленных пользователей, которых еще можно восстановить
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+}
+
+// HasPermission сообщает, дает ли хотя бы одна из ролей пользователя право perm
+func (u *User) HasPermission(perm string) bool {
+	return slices.Contains(u.Permissions, perm)
+}
@@ -1,6 +1,8 @@
-package models
-
-type UserCreate struct {
-	Name  string `json:"name" binding:"required,min=1,max=100"`
-	Email string `json:"email" binding:"required,email,max=150"`
-}
+package models
+
+type UserCreate struct {
+	Name  string `json:"name" binding:"required,min=1,max=100"`
+	Email string `json:"email" binding:"required,email,max=150"`
+	// bcrypt учитывает только первые 72 байта пароля
+	Password string `json:"password" binding:"required,min=8,max=72"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+// Виды заданий с данными пользователя
+const (
+	// DataJobExport выгружает данные пользователя в ZIP-архив
+	DataJobExport = "export"
+	// DataJobErasure стирает персональные данные и удаляет объявления
+	DataJobErasure = "erasure"
+)
+
+// Статусы заданий с данными пользователя
+const (
+	DataJobPending = "pending"
+	DataJobRunning = "running"
+	DataJobDone    = "done"
+	DataJobFailed  = "failed"
+)
+
+// UserDataJob - задание на выгрузку или стирание данных пользователя
+type UserDataJob struct {
+	ID          int64  `json:"id"`
+	Kind        string `json:"kind"`
+	UserID      int    `json:"user_id"`
+	RequestedBy int    `json:"requested_by"`
+	Status      string `json:"status"`
+	Error       string `json:"error,omitempty"`
+	// ResultKey - имя файла выгрузки в каталоге результатов
+	ResultKey       string     `json:"-"`
+	ResultExpiresAt *time.Time `json:"result_expires_at,omitempty"`
+	// DownloadURL заполняется, пока выгрузку можно скачать
+	DownloadURL string     `json:"download_url,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// UserExport - содержимое data.json в архиве выгрузки
+type UserExport struct {
+	ExportedAt time.Time           `json:"exported_at"`
+	User       User                `json:"user"`
+	Ads        []UserExportAd      `json:"ads"`
+	APIKeys    []APIKey            `json:"api_keys"`
+	Sessions   []UserExportSession `json:"sessions"`
+}
+
+// UserExportAd - объявление пользователя, включая удаленные
+type UserExportAd struct {
+	ID          int               `json:"id"`
+	Category    Category          `json:"category"`
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	Price       float64           `json:"price"`
+	Attributes  AdAttributes      `json:"attributes"`
+	IsEnabled   bool              `json:"is_enabled"`
+	CreatedAt   time.Time         `json:"created_at"`
+	DeletedAt   *time.Time        `json:"deleted_at,omitempty"`
+	Images      []UserExportImage `json:"images"`
+}
+
+// UserExportImage - изображение объявления; File - путь к файлу внутри архива
+type UserExportImage struct {
+	Filename string `json:"-"`
+	File     string `json:"file"`
+	Position int    `json:"position"`
+	IsCover  bool   `json:"is_cover"`
+}
+
+// UserExportSession - сессия входа пользователя
+type UserExportSession struct {
+	ID        string     `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
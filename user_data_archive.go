@@ -0,0 +1,79 @@
+package userdata
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+
+	"golang-test/internal/models"
+	"golang-test/internal/storage"
+)
+
+// writeArchive записывает ZIP-архив выгрузки: data.json с данными
+// пользователя и оригиналы изображений его объявлений в каталоге images/
+func writeArchive(ctx context.Context, w io.Writer, store storage.Storage, data *models.UserExport) error {
+	zw := zip.NewWriter(w)
+
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     "data.json",
+		Method:   zip.Deflate,
+		Modified: data.ExportedAt,
+	})
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		return err
+	}
+
+	// Один файл может быть у нескольких объявлений
+	written := make(map[string]bool)
+	for _, ad := range data.Ads {
+		for _, img := range ad.Images {
+			if written[img.Filename] {
+				continue
+			}
+			written[img.Filename] = true
+
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := copyImage(ctx, zw, store, img, data); err != nil {
+				return err
+			}
+		}
+	}
+
+	return zw.Close()
+}
+
+// copyImage добавляет файл изображения в архив. Отсутствующий в хранилище
+// файл пропускается: запись о нем остается в data.json.
+func copyImage(ctx context.Context, zw *zip.Writer, store storage.Storage, img models.UserExportImage, data *models.UserExport) error {
+	r, obj, err := store.Get(ctx, img.Filename)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			slog.Warn("image file is missing from export", "file", img.Filename, "user_id", data.User.ID)
+			return nil
+		}
+		return err
+	}
+	defer r.Close()
+
+	// Изображения уже сжаты, повторно их не сжимаем
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     img.File,
+		Method:   zip.Store,
+		Modified: obj.ModTime,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return err
+}
@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"golang-test/internal/middleware"
+	"golang-test/internal/models"
+	"golang-test/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+// UserDataHandler ставит в очередь выгрузку и стирание данных пользователя
+// и отдает результаты. Сами задания выполняет userdata.Worker.
+type UserDataHandler struct {
+	repo *repository.UserDataRepository
+	// dir - каталог архивов выгрузок
+	dir string
+}
+
+func NewUserDataHandler(repo *repository.UserDataRepository, dir string) *UserDataHandler {
+	return &UserDataHandler{repo: repo, dir: dir}
+}
+
+// withDownloadURL заполняет ссылку на скачивание, пока архив доступен
+func withDownloadURL(job *models.UserDataJob) *models.UserDataJob {
+	if job.ResultKey != "" {
+		job.DownloadURL = fmt.Sprintf("/users/%d/data-jobs/%d/download", job.UserID, job.ID)
+	}
+	return job
+}
+
+// RequestExport ставит в очередь выгрузку данных пользователя
+// @Summary Запросить выгрузку данных
+// @Description Ставит в очередь сборку ZIP-архива с данными пользователя: data.json (профиль, объявления, включая удаленные, API-ключи, сессии) и изображения объявлений. Доступно самому пользователю и с правом users:manage. Готовый архив скачивается по download_url задания
+// @Tags users
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 202 {object} models.UserDataJob
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users/{id}/export [post]
+func (h *UserDataHandler) RequestExport(c *gin.Context) {
+	h.createJob(c, models.DataJobExport)
+}
+
+// RequestErasure ставит в очередь стирание данных пользователя
+// @Summary Запросить стирание данных
+// @Description Ставит в очередь стирание данных: пользователь обезличивается и удаляется, его объявления, изображения и выгрузки удаляются окончательно, сессии завершаются. Отменить стирание нельзя. Доступно самому пользователю и с правом users:manage
+// @Tags users
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 202 {object} models.UserDataJob
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users/{id}/erasure [post]
+func (h *UserDataHandler) RequestErasure(c *gin.Context) {
+	h.createJob(c, models.DataJobErasure)
+}
+
+// createJob ставит в очередь задание вида kind для пользователя из пути запроса
+func (h *UserDataHandler) createJob(c *gin.Context, kind string) {
+	id, ok := authorizeAccount(c)
+	if !ok {
+		return
+	}
+
+	requestedBy := middleware.CurrentUser(c).ID
+	job, err := h.repo.CreateJob(c.Request.Context(), kind, id, requestedBy)
+	if err != nil {
+		switch {
+		case err.Error() == fmt.Sprintf("user with id %d does not exist", id):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "user not found",
+			})
+		case err.Error() == fmt.Sprintf("user with id %d already has an unfinished %s job", id, kind):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": fmt.Sprintf("%s is already in progress", kind),
+			})
+		default:
+			slog.Error("failed to create user data job", "error", err, "kind", kind, "user_id", id)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to create job",
+			})
+		}
+		return
+	}
+
+	slog.Info("user data job requested", "id", job.ID, "kind", kind, "user_id", id, "requested_by", requestedBy)
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetExport возвращает последнюю выгрузку данных пользователя
+// @Summary Получить последнюю выгрузку данных
+// @Description Возвращает последнее задание на выгрузку; пока архив доступен, в нем есть download_url
+// @Tags users
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} models.UserDataJob
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users/{id}/export [get]
+func (h *UserDataHandler) GetExport(c *gin.Context) {
+	id, ok := authorizeAccount(c)
+	if !ok {
+		return
+	}
+
+	jobs, err := h.repo.GetJobs(c.Request.Context(), id, models.DataJobExport)
+	if err != nil {
+		slog.Error("failed to get user data jobs", "error", err, "user_id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+	if len(jobs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "export not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, withDownloadURL(&jobs[0]))
+}
+
+// GetDataJobs возвращает журнал заданий с данными пользователя
+// @Summary Журнал выгрузок и стираний
+// @Description Возвращает все задания на выгрузку и стирание данных пользователя, начиная с последнего: кто и когда их запросил и чем они закончились
+// @Tags users
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {array} models.UserDataJob
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users/{id}/data-jobs [get]
+func (h *UserDataHandler) GetDataJobs(c *gin.Context) {
+	id, ok := authorizeAccount(c)
+	if !ok {
+		return
+	}
+
+	jobs, err := h.repo.GetJobs(c.Request.Context(), id, "")
+	if err != nil {
+		slog.Error("failed to get user data jobs", "error", err, "user_id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	for i := range jobs {
+		withDownloadURL(&jobs[i])
+	}
+	c.JSON(http.StatusOK, jobs)
+}
+
+// GetDataJob возвращает задание с данными пользователя
+// @Summary Получить задание
+// @Description Возвращает статус задания на выгрузку или стирание данных
+// @Tags users
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param jobId path int true "ID задания"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} models.UserDataJob
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users/{id}/data-jobs/{jobId} [get]
+func (h *UserDataHandler) GetDataJob(c *gin.Context) {
+	job, ok := h.getJob(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, withDownloadURL(job))
+}
+
+// DownloadDataJob отдает архив выгрузки
+// @Summary Скачать выгрузку данных
+// @Description Отдает ZIP-архив выполненной выгрузки, пока не истек срок его хранения
+// @Tags users
+// @Produce application/zip
+// @Param id path int true "ID пользователя"
+// @Param jobId path int true "ID задания"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {file} file
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users/{id}/data-jobs/{jobId}/download [get]
+func (h *UserDataHandler) DownloadDataJob(c *gin.Context) {
+	job, ok := h.getJob(c)
+	if !ok {
+		return
+	}
+
+	if job.Kind != models.DataJobExport || job.Status != models.DataJobDone {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": "job has no downloadable result",
+		})
+		return
+	}
+
+	path := filepath.Join(h.dir, job.ResultKey)
+	expired := job.ResultKey == "" || (job.ResultExpiresAt != nil && job.ResultExpiresAt.Before(time.Now()))
+	if !expired {
+		if _, err := os.Stat(path); err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				slog.Error("failed to open user data export", "error", err, "id", job.ID)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error": "internal server error",
+				})
+				return
+			}
+			expired = true
+		}
+	}
+	if expired {
+		c.JSON(http.StatusGone, gin.H{
+			"error": "export has expired",
+		})
+		return
+	}
+
+	slog.Info("user data export downloaded", "id", job.ID, "user_id", job.UserID, "downloaded_by", middleware.CurrentUser(c).ID)
+	c.FileAttachment(path, fmt.Sprintf("user-%d-export.zip", job.UserID))
+}
+
+// getJob загружает задание из пути запроса.
+// При ошибке ответ клиенту отправляется здесь же.
+func (h *UserDataHandler) getJob(c *gin.Context) (*models.UserDataJob, bool) {
+	userID, ok := authorizeAccount(c)
+	if !ok {
+		return nil, false
+	}
+
+	id, err := strconv.ParseInt(c.Param("jobId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid job id",
+		})
+		return nil, false
+	}
+
+	job, err := h.repo.GetJob(c.Request.Context(), userID, id)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("job with id %d does not exist", id) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "job not found",
+			})
+			return nil, false
+		}
+		slog.Error("failed to get user data job", "error", err, "id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return nil, false
+	}
+
+	return job, true
+}
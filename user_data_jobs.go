@@ -0,0 +1,221 @@
+package userdata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang-test/internal/models"
+	"golang-test/internal/repository"
+	"golang-test/internal/storage"
+)
+
+// staleJobTimeout - задание, которое выполняется дольше, считается брошенным
+// (например, сервер остановился посреди выгрузки) и возвращается в очередь
+const staleJobTimeout = time.Hour
+
+// Config - настройки выполнения заданий с данными пользователей
+type Config struct {
+	// Interval - период опроса очереди; 0 отключает фоновое выполнение
+	Interval time.Duration
+	// Dir - каталог, в котором хранятся архивы выгрузок
+	Dir string
+	// ResultTTL - сколько архив доступен для скачивания
+	ResultTTL time.Duration
+}
+
+// ConfigFromEnv читает настройки из переменных окружения:
+//
+//	USER_DATA_JOBS_INTERVAL  период опроса очереди, по умолчанию 10s; 0 отключает его
+//	USER_DATA_EXPORT_DIR     каталог архивов выгрузок, по умолчанию exports
+//	USER_DATA_EXPORT_TTL     срок хранения архива, по умолчанию 168h
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{Interval: 10 * time.Second, Dir: "exports", ResultTTL: 7 * 24 * time.Hour}
+
+	if v := os.Getenv("USER_DATA_JOBS_INTERVAL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d < 0 {
+			return cfg, errors.New("invalid USER_DATA_JOBS_INTERVAL")
+		}
+		cfg.Interval = d
+	}
+	if v := os.Getenv("USER_DATA_EXPORT_DIR"); v != "" {
+		cfg.Dir = v
+	}
+	if v := os.Getenv("USER_DATA_EXPORT_TTL"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 {
+			return cfg, errors.New("invalid USER_DATA_EXPORT_TTL")
+		}
+		cfg.ResultTTL = d
+	}
+	return cfg, nil
+}
+
+// Worker выполняет задания на выгрузку и стирание данных пользователей.
+// Задания ставят в очередь обработчики API, история остается в базе.
+type Worker struct {
+	jobs      *repository.UserDataRepository
+	users     *repository.UserRepository
+	ads       *repository.AdRepository
+	store     storage.Storage
+	dir       string
+	resultTTL time.Duration
+}
+
+func NewWorker(jobs *repository.UserDataRepository, users *repository.UserRepository, ads *repository.AdRepository, store storage.Storage, cfg Config) *Worker {
+	return &Worker{jobs: jobs, users: users, ads: ads, store: store, dir: cfg.Dir, resultTTL: cfg.ResultTTL}
+}
+
+// Run удаляет архивы с истекшим сроком хранения и выполняет все задания
+// из очереди. Возвращает количество выполненных заданий.
+func (w *Worker) Run(ctx context.Context) (int, error) {
+	if err := w.jobs.RequeueStale(ctx, time.Now().Add(-staleJobTimeout)); err != nil {
+		return 0, err
+	}
+	w.removeResults(ctx, time.Now(), 0)
+
+	done := 0
+	for ctx.Err() == nil {
+		job, err := w.jobs.ClaimJob(ctx)
+		if err != nil {
+			return done, err
+		}
+		if job == nil {
+			break
+		}
+		w.process(ctx, job)
+		done++
+	}
+	return done, nil
+}
+
+// Start запускает периодическое выполнение до отмены ctx; interval <= 0 отключает его
+func (w *Worker) Start(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if _, err := w.Run(ctx); err != nil {
+				slog.Error("user data jobs failed", "error", err)
+			}
+		}
+	}()
+}
+
+// process выполняет задание и записывает результат. Ошибка сохраняется
+// в задании и видна тому, кто его запросил.
+func (w *Worker) process(ctx context.Context, job *models.UserDataJob) {
+	slog.Info("user data job started", "id", job.ID, "kind", job.Kind, "user_id", job.UserID, "requested_by", job.RequestedBy)
+
+	var resultKey string
+	var expiresAt *time.Time
+	var err error
+	switch job.Kind {
+	case models.DataJobExport:
+		resultKey, err = w.export(ctx, job)
+		if err == nil {
+			t := time.Now().Add(w.resultTTL)
+			expiresAt = &t
+		}
+	case models.DataJobErasure:
+		err = w.erase(ctx, job)
+	default:
+		err = fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+
+	// Результат записывается и при остановке сервера, иначе задание
+	// вернется в очередь только через staleJobTimeout
+	ctx = context.WithoutCancel(ctx)
+	if err != nil {
+		slog.Error("user data job failed", "error", err, "id", job.ID, "kind", job.Kind, "user_id", job.UserID)
+		if err := w.jobs.FailJob(ctx, job.ID, err.Error()); err != nil {
+			slog.Error("failed to save user data job status", "error", err, "id", job.ID)
+		}
+		return
+	}
+
+	if err := w.jobs.FinishJob(ctx, job.ID, resultKey, expiresAt); err != nil {
+		slog.Error("failed to save user data job status", "error", err, "id", job.ID)
+		return
+	}
+	slog.Info("user data job finished", "id", job.ID, "kind", job.Kind, "user_id", job.UserID)
+}
+
+// export собирает архив с данными пользователя и возвращает имя его файла
+func (w *Worker) export(ctx context.Context, job *models.UserDataJob) (string, error) {
+	data, err := w.jobs.Export(ctx, job.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	if err := os.MkdirAll(w.dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create export directory: %w", err)
+	}
+
+	// Архив пишется во временный файл и переименовывается целиком,
+	// чтобы недописанный файл нельзя было скачать
+	name := fmt.Sprintf("user-%d-export-%d.zip", job.UserID, job.ID)
+	f, err := os.CreateTemp(w.dir, name+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+
+	if err := writeArchive(ctx, f, w.store, data); err != nil {
+		f.Close()
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Rename(f.Name(), filepath.Join(w.dir, name)); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// erase стирает данные пользователя, удаляет ставшие ненужными файлы
+// изображений и все выгрузки этого пользователя
+func (w *Worker) erase(ctx context.Context, job *models.UserDataJob) error {
+	unused, err := w.users.Erase(ctx, job.UserID)
+	if err != nil {
+		return err
+	}
+	w.ads.DiscardImages(ctx, unused)
+
+	// Выгрузки содержат персональные данные и не должны пережить стирание
+	w.removeResults(ctx, time.Time{}, job.UserID)
+	return nil
+}
+
+// removeResults удаляет архивы с истекшим сроком хранения, а при userID > 0 -
+// все архивы пользователя. Ошибки логируются.
+func (w *Worker) removeResults(ctx context.Context, before time.Time, userID int) {
+	keys, err := w.jobs.TakeResults(ctx, before, userID)
+	if err != nil {
+		slog.Error("failed to take user data exports", "error", err, "user_id", userID)
+		return
+	}
+
+	for _, key := range keys {
+		if err := os.Remove(filepath.Join(w.dir, key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Error("failed to remove user data export", "error", err, "file", key)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"golang-test/internal/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// UserDataRepository хранит задания на выгрузку и стирание данных
+// пользователя и собирает данные для выгрузки
+type UserDataRepository struct {
+	db *sql.DB
+}
+
+func NewUserDataRepository(db *sql.DB) *UserDataRepository {
+	return &UserDataRepository{db: db}
+}
+
+const userDataJobColumns = `id, kind, user_id, requested_by, status, error, COALESCE(result_key, ''),
+	result_expires_at, created_at, started_at, finished_at`
+
+func scanUserDataJob(row interface{ Scan(...interface{}) error }, job *models.UserDataJob) error {
+	return row.Scan(&job.ID, &job.Kind, &job.UserID, &job.RequestedBy, &job.Status, &job.Error, &job.ResultKey,
+		&job.ResultExpiresAt, &job.CreatedAt, &job.StartedAt, &job.FinishedAt)
+}
+
+// CreateJob ставит задание в очередь. У пользователя может быть только одно
+// незавершенное задание каждого вида. Стереть можно и удаленного пользователя,
+// выгрузить - только действующего.
+func (r *UserDataRepository) CreateJob(ctx context.Context, kind string, userID, requestedBy int) (*models.UserDataJob, error) {
+	var job models.UserDataJob
+	err := scanUserDataJob(r.db.QueryRowContext(ctx, `
+		INSERT INTO user_data_jobs (kind, user_id, requested_by)
+		SELECT $1, id, $3 FROM users
+		WHERE id = $2 AND (deleted_at IS NULL OR $1 = 'erasure')
+		RETURNING `+userDataJobColumns,
+		kind, userID, requestedBy), &job)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user with id %d does not exist", userID)
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("user with id %d already has an unfinished %s job", userID, kind)
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetJob возвращает задание пользователя userID
+func (r *UserDataRepository) GetJob(ctx context.Context, userID int, id int64) (*models.UserDataJob, error) {
+	var job models.UserDataJob
+	err := scanUserDataJob(r.db.QueryRowContext(ctx,
+		"SELECT "+userDataJobColumns+" FROM user_data_jobs WHERE id = $1 AND user_id = $2", id, userID), &job)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("job with id %d does not exist", id)
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetJobs возвращает задания пользователя, начиная с последнего; kind
+// ограничивает выборку одним видом заданий, пустой - все
+func (r *UserDataRepository) GetJobs(ctx context.Context, userID int, kind string) ([]models.UserDataJob, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+userDataJobColumns+`
+		FROM user_data_jobs
+		WHERE user_id = $1 AND ($2 = '' OR kind = $2)
+		ORDER BY id DESC
+	`, userID, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	jobs := []models.UserDataJob{}
+	for rows.Next() {
+		var job models.UserDataJob
+		if err := scanUserDataJob(rows, &job); err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// ClaimJob забирает самое старое задание из очереди и помечает его
+// выполняемым. Если очередь пуста, возвращается nil без ошибки.
+func (r *UserDataRepository) ClaimJob(ctx context.Context) (*models.UserDataJob, error) {
+	var job models.UserDataJob
+	err := scanUserDataJob(r.db.QueryRowContext(ctx, `
+		UPDATE user_data_jobs SET status = 'running', started_at = NOW()
+		WHERE id = (
+			SELECT id FROM user_data_jobs
+			WHERE status = 'pending'
+			ORDER BY id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+userDataJobColumns), &job)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &job, nil
+}
+
+// RequeueStale возвращает в очередь задания, начатые раньше before и так
+// и не завершенные
+func (r *UserDataRepository) RequeueStale(ctx context.Context, before time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE user_data_jobs SET status = 'pending', started_at = NULL
+		WHERE status = 'running' AND started_at < $1
+	`, before)
+	return err
+}
+
+// FinishJob помечает задание выполненным; resultKey пуст у заданий без результата
+func (r *UserDataRepository) FinishJob(ctx context.Context, id int64, resultKey string, expiresAt *time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE user_data_jobs
+		SET status = 'done', result_key = NULLIF($2, ''), result_expires_at = $3, finished_at = NOW()
+		WHERE id = $1
+	`, id, resultKey, expiresAt)
+	return err
+}
+
+// FailJob помечает задание завершившимся с ошибкой
+func (r *UserDataRepository) FailJob(ctx context.Context, id int64, message string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE user_data_jobs SET status = 'failed', error = $2, finished_at = NOW()
+		WHERE id = $1
+	`, id, message)
+	return err
+}
+
+// TakeResults отвязывает от заданий файлы выгрузок и возвращает их имена для
+// удаления: с истекшим сроком хранения, а при userID > 0 - все выгрузки
+// этого пользователя. Сами задания остаются в журнале.
+func (r *UserDataRepository) TakeResults(ctx context.Context, before time.Time, userID int) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		WITH taken AS (
+			SELECT id, result_key FROM user_data_jobs
+			WHERE result_key IS NOT NULL AND (result_expires_at < $1 OR user_id = $2)
+			FOR UPDATE
+		)
+		UPDATE user_data_jobs j SET result_key = NULL
+		FROM taken
+		WHERE j.id = taken.id
+		RETURNING taken.result_key
+	`, before, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// Export собирает данные пользователя для выгрузки: профиль, все объявления,
+// включая удаленные, API-ключи и сессии. Данные читаются из одного снимка базы.
+func (r *UserDataRepository) Export(ctx context.Context, userID int) (*models.UserExport, error) {
+	tx, err := r.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	export := &models.UserExport{ExportedAt: time.Now()}
+	err = tx.QueryRowContext(ctx, "SELECT id, name, email, email_verified_at, created_at FROM users WHERE id = $1 AND deleted_at IS NULL", userID).
+		Scan(&export.User.ID, &export.User.Name, &export.User.Email, &export.User.EmailVerifiedAt, &export.User.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user with id %d does not exist", userID)
+		}
+		return nil, err
+	}
+	if err := loadUserAccess(ctx, tx, &export.User); err != nil {
+		return nil, err
+	}
+
+	if export.Ads, err = exportAds(ctx, tx, userID); err != nil {
+		return nil, err
+	}
+
+	export.APIKeys = []models.APIKey{}
+	rows, err := tx.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = $1 ORDER BY id", userID)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var key models.APIKey
+		if err := scanAPIKey(rows, &key); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		export.APIKeys = append(export.APIKeys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	export.Sessions = []models.UserExportSession{}
+	rows, err = tx.QueryContext(ctx, "SELECT id, created_at, revoked_at FROM auth_sessions WHERE user_id = $1 ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var session models.UserExportSession
+		if err := rows.Scan(&session.ID, &session.CreatedAt, &session.RevokedAt); err != nil {
+			return nil, err
+		}
+		export.Sessions = append(export.Sessions, session)
+	}
+	return export, rows.Err()
+}
+
+// exportAds возвращает все объявления пользователя с изображениями
+func exportAds(ctx context.Context, tx *sql.Tx, userID int) ([]models.UserExportAd, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT
+			a.id, a.title, a.description, a.price, a.attributes, a.is_enabled, a.created_at, a.deleted_at,
+			c.id, c.name, c.parent_id
+		FROM ads a
+		JOIN categories c ON a.category_id = c.id
+		WHERE a.user_id = $1
+		ORDER BY a.id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ads := []models.UserExportAd{}
+	var ids []int
+	for rows.Next() {
+		var ad models.UserExportAd
+		err := rows.Scan(
+			&ad.ID, &ad.Title, &ad.Description, &ad.Price, &ad.Attributes, &ad.IsEnabled, &ad.CreatedAt, &ad.DeletedAt,
+			&ad.Category.ID, &ad.Category.Name, &ad.Category.ParentID,
+		)
+		if err != nil {
+			return nil, err
+		}
+		ads = append(ads, ad)
+		ids = append(ids, ad.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	images, err := loadAdImages(ctx, tx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range ads {
+		ads[i].Images = []models.UserExportImage{}
+		for _, img := range images[ads[i].ID] {
+			ads[i].Images = append(ads[i].Images, models.UserExportImage{
+				Filename: img.Filename,
+				File:     "images/" + img.Filename,
+				Position: img.Position,
+				IsCover:  img.IsCover,
+			})
+		}
+	}
+	return ads, nil
+}
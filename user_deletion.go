@@ -0,0 +1,36 @@
+package models
+
+import "slices"
+
+// Политики удаления пользователя, у которого есть объявления
+const (
+	// DeletionCascade удаляет объявления вместе с пользователем
+	DeletionCascade = "cascade"
+	// DeletionReassign передает объявления другому пользователю
+	DeletionReassign = "reassign"
+	// DeletionBlock запрещает удаление, пока у пользователя есть объявления
+	DeletionBlock = "block"
+	// DeletionAnonymize стирает данные пользователя, оставляя его объявления
+	DeletionAnonymize = "anonymize"
+)
+
+// DeletionPolicies - все допустимые политики удаления
+var DeletionPolicies = []string{DeletionCascade, DeletionReassign, DeletionBlock, DeletionAnonymize}
+
+// IsDeletionPolicy проверяет название политики удаления
+func IsDeletionPolicy(policy string) bool {
+	return slices.Contains(DeletionPolicies, policy)
+}
+
+// UserDeletion - параметры удаления пользователя
+type UserDeletion struct {
+	Policy string
+	// ReassignTo - новый владелец объявлений для политики reassign
+	ReassignTo int
+}
+
+// AdRef - краткая ссылка на объявление
+type AdRef struct {
+	ID    int    `json:"id"`
+	Title string `json:"title"`
+}
@@ -0,0 +1,24 @@
+package models
+
+// UserFilter описывает параметры выборки списка пользователей
+type UserFilter struct {
+	Page  int
+	Limit int
+	// Query ищется без учета регистра в имени и email
+	Query string
+	// Deleted выбирает удаленных пользователей вместо действующих
+	Deleted bool
+}
+
+// Offset возвращает смещение для текущей страницы
+func (f UserFilter) Offset() int {
+	return (f.Page - 1) * f.Limit
+}
+
+type UserList struct {
+	Items []User    `json:"items"`
+	Total int       `json:"total"`
+	Page  int       `json:"page"`
+	Limit int       `json:"limit"`
+	Links PageLinks `json:"links"`
+}
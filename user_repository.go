@@ -1,51 +1,544 @@
-package repository
-
-import (
-	"context"
-	"database/sql"
-	"fmt"
-	"golang-test/internal/models"
-)
-
-type UserRepository struct {
-	db *sql.DB
-}
-
-func NewUserRepository(db *sql.DB) *UserRepository {
-	return &UserRepository{db: db}
-}
-
-func (r *UserRepository) Create(ctx context.Context, user *models.UserCreate) (*models.User, error) {
-	query := `
-		INSERT INTO users (name, email)
-		VALUES ($1, $2)
-		RETURNING id, created_at
-	`
-
-	var newUser models.User
-	newUser.Name = user.Name
-	newUser.Email = user.Email
-
-	err := r.db.QueryRowContext(ctx, query, user.Name, user.Email).Scan(&newUser.ID, &newUser.CreatedAt)
-	if err != nil {
-		return nil, err
-	}
-
-	return &newUser, nil
-}
-
-func (r *UserRepository) Delete(ctx context.Context, id int) error {
-	// Проверяем существование пользователя
-	var exists bool
-	err := r.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1)", id).Scan(&exists)
-	if err != nil {
-		return err
-	}
-	if !exists {
-		return fmt.Errorf("user with id %d does not exist", id)
-	}
-
-	// Удаляем пользователя (объявления удалятся каскадно из-за FOREIGN KEY)
-	_, err = r.db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
-	return err
-}
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang-test/internal/models"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type UserRepository struct {
+	db *sql.DB
+}
+
+func NewUserRepository(db *sql.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// Create создает пользователя с ролью user; пароль передается уже захешированным
+func (r *UserRepository) Create(ctx context.Context, user *models.UserCreate, passwordHash string) (*models.User, error) {
+	query := `
+		WITH u AS (
+			INSERT INTO users (name, email, password_hash)
+			VALUES ($1, $2, $3)
+			RETURNING id, created_at
+		), ur AS (
+			INSERT INTO user_roles (user_id, role_id)
+			SELECT u.id, roles.id FROM u, roles WHERE roles.name = $4
+		)
+		SELECT id, created_at FROM u
+	`
+
+	var newUser models.User
+	newUser.Name = user.Name
+	newUser.Email = user.Email
+
+	err := r.db.QueryRowContext(ctx, query, user.Name, user.Email, passwordHash, models.RoleUser).Scan(&newUser.ID, &newUser.CreatedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("user with email %s already exists", user.Email)
+		}
+		return nil, err
+	}
+
+	if err := loadUserAccess(ctx, r.db, &newUser); err != nil {
+		return nil, err
+	}
+
+	return &newUser, nil
+}
+
+// GetCredentials возвращает пользователя и хеш его пароля по email.
+// Если пользователя нет, возвращается nil без ошибки.
+func (r *UserRepository) GetCredentials(ctx context.Context, email string) (*models.User, string, error) {
+	query := `
+		SELECT id, name, email, email_verified_at, created_at, password_hash
+		FROM users
+		WHERE email = $1 AND deleted_at IS NULL
+	`
+
+	var user models.User
+	var passwordHash string
+	err := r.db.QueryRowContext(ctx, query, email).Scan(&user.ID, &user.Name, &user.Email, &user.EmailVerifiedAt, &user.CreatedAt, &passwordHash)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", nil
+		}
+		return nil, "", err
+	}
+
+	if err := loadUserAccess(ctx, r.db, &user); err != nil {
+		return nil, "", err
+	}
+
+	return &user, passwordHash, nil
+}
+
+// GetByID возвращает пользователя с ролями и правами
+func (r *UserRepository) GetByID(ctx context.Context, id int) (*models.User, error) {
+	var user models.User
+	err := r.db.QueryRowContext(ctx, "SELECT id, name, email, email_verified_at, created_at FROM users WHERE id = $1 AND deleted_at IS NULL", id).
+		Scan(&user.ID, &user.Name, &user.Email, &user.EmailVerifiedAt, &user.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user with id %d does not exist", id)
+		}
+		return nil, err
+	}
+
+	if err := loadUserAccess(ctx, r.db, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// GetAll возвращает страницу пользователей с ролями и общее количество подходящих записей
+func (r *UserRepository) GetAll(ctx context.Context, filter models.UserFilter) ([]models.User, int, error) {
+	where := "WHERE u.deleted_at IS NULL"
+	if filter.Deleted {
+		where = "WHERE u.deleted_at IS NOT NULL"
+	}
+	args := []interface{}{}
+	if filter.Query != "" {
+		args = append(args, "%"+escapeLike(filter.Query)+"%")
+		where += " AND (u.name ILIKE $1 OR u.email ILIKE $1)"
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users u "+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+
+	query := fmt.Sprintf(`
+		SELECT
+			u.id, u.name, u.email, u.email_verified_at, u.created_at, u.deleted_at,
+			COALESCE(string_agg(r.name, ',' ORDER BY r.name), '')
+		FROM users u
+		LEFT JOIN user_roles ur ON ur.user_id = u.id
+		LEFT JOIN roles r ON r.id = ur.role_id
+		%s
+		GROUP BY u.id
+		ORDER BY u.id
+		LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+
+	rows, err := r.db.QueryContext(ctx, query, append(args, filter.Limit, filter.Offset())...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	users := make([]models.User, 0, filter.Limit)
+	for rows.Next() {
+		var user models.User
+		var roles string
+		if err := rows.Scan(&user.ID, &user.Name, &user.Email, &user.EmailVerifiedAt, &user.CreatedAt, &user.DeletedAt, &roles); err != nil {
+			return nil, 0, err
+		}
+		user.Roles = splitList(roles)
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return users, total, nil
+}
+
+// escapeLike экранирует спецсимволы шаблона LIKE
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// Update меняет имя и/или email пользователя и возвращает обновленный профиль.
+// Новый email требует повторного подтверждения.
+func (r *UserRepository) Update(ctx context.Context, id int, update *models.UserUpdate) (*models.User, error) {
+	var user models.User
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE users
+		SET name = COALESCE($2, name),
+			email = COALESCE($3, email),
+			email_verified_at = CASE WHEN $3::VARCHAR IS NULL OR $3 = email THEN email_verified_at END
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING id, name, email, email_verified_at, created_at
+	`, id, update.Name, update.Email).Scan(&user.ID, &user.Name, &user.Email, &user.EmailVerifiedAt, &user.CreatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user with id %d does not exist", id)
+		}
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("user with email %s already exists", *update.Email)
+		}
+		return nil, err
+	}
+
+	if err := loadUserAccess(ctx, r.db, &user); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// UserHasAdsError возвращается политикой block, если у пользователя есть объявления
+type UserHasAdsError struct {
+	Ads []models.AdRef
+}
+
+func (e *UserHasAdsError) Error() string {
+	return fmt.Sprintf("user has %d ads", len(e.Ads))
+}
+
+// Delete помечает пользователя удаленным и завершает его сессии; объявления
+// обрабатываются по политике deletion. Все изменения выполняются в одной транзакции.
+//
+// При политике cascade объявления получают ту же отметку времени, что
+// и пользователь, поэтому при восстановлении возвращаются только они, а не
+// удаленные им раньше.
+func (r *UserRepository) Delete(ctx context.Context, id int, deletion models.UserDeletion) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Та же блокировка, что и при смене ролей: проверка последнего
+	// администратора не должна пропустить параллельные изменения
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE user_roles IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return err
+	}
+
+	var deletedAt time.Time
+	err = tx.QueryRowContext(ctx, `
+		UPDATE users SET deleted_at = NOW()
+		WHERE id = $1 AND deleted_at IS NULL
+		RETURNING deleted_at
+	`, id).Scan(&deletedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("user with id %d does not exist", id)
+		}
+		return err
+	}
+
+	admins, err := countAdmins(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if admins == 0 {
+		return fmt.Errorf("cannot delete the last administrator")
+	}
+
+	switch deletion.Policy {
+	case models.DeletionCascade:
+		_, err = tx.ExecContext(ctx, "UPDATE ads SET deleted_at = $2 WHERE user_id = $1 AND deleted_at IS NULL", id, deletedAt)
+	case models.DeletionReassign:
+		err = reassignAds(ctx, tx, id, deletion.ReassignTo)
+	case models.DeletionBlock:
+		err = blockIfHasAds(ctx, tx, id)
+	case models.DeletionAnonymize:
+		err = anonymizeUser(ctx, tx, id)
+	default:
+		err = fmt.Errorf("unknown deletion policy %q", deletion.Policy)
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE auth_sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// reassignAds передает все объявления пользователя, включая удаленные, другому
+// действующему пользователю, чтобы удаленного пользователя можно было очистить
+func reassignAds(ctx context.Context, tx *sql.Tx, from, to int) error {
+	var exists bool
+	err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL)", to).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists || to == from {
+		return fmt.Errorf("reassign target user with id %d does not exist", to)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE ads SET user_id = $2 WHERE user_id = $1", from, to)
+	return err
+}
+
+// blockIfHasAds запрещает удаление пользователя с действующими объявлениями
+func blockIfHasAds(ctx context.Context, tx *sql.Tx, id int) error {
+	rows, err := tx.QueryContext(ctx, "SELECT id, title FROM ads WHERE user_id = $1 AND deleted_at IS NULL ORDER BY id", id)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	ads := []models.AdRef{}
+	for rows.Next() {
+		var ad models.AdRef
+		if err := rows.Scan(&ad.ID, &ad.Title); err != nil {
+			return err
+		}
+		ads = append(ads, ad)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	if len(ads) > 0 {
+		return &UserHasAdsError{Ads: ads}
+	}
+	return nil
+}
+
+// anonymizeUser стирает персональные данные пользователя. Его объявления
+// остаются опубликованными от имени обезличенного владельца.
+func anonymizeUser(ctx context.Context, tx *sql.Tx, id int) error {
+	_, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET name = 'Deleted user',
+			email = 'deleted-' || id || '@anonymized.invalid',
+			password_hash = '',
+			email_verified_at = NULL,
+			anonymized_at = NOW()
+		WHERE id = $1
+	`, id)
+	if err != nil {
+		return err
+	}
+
+	// Ключи и одноразовые токены больше не нужны
+	if _, err := tx.ExecContext(ctx, "DELETE FROM api_keys WHERE user_id = $1", id); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "DELETE FROM email_tokens WHERE user_id = $1", id)
+	return err
+}
+
+// Restore снимает пометку удаления с пользователя и объявлений, удаленных
+// вместе с ним. Обезличенного пользователя восстановить нельзя.
+func (r *UserRepository) Restore(ctx context.Context, id int) (*models.User, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var email string
+	var deletedAt, anonymizedAt sql.NullTime
+	err = tx.QueryRowContext(ctx, "SELECT email, deleted_at, anonymized_at FROM users WHERE id = $1 FOR UPDATE", id).
+		Scan(&email, &deletedAt, &anonymizedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("user with id %d does not exist", id)
+		}
+		return nil, err
+	}
+	if !deletedAt.Valid {
+		return nil, fmt.Errorf("user with id %d is not deleted", id)
+	}
+	if anonymizedAt.Valid {
+		return nil, fmt.Errorf("user with id %d is anonymized", id)
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE users SET deleted_at = NULL WHERE id = $1", id)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			return nil, fmt.Errorf("user with email %s already exists", email)
+		}
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE ads SET deleted_at = NULL WHERE user_id = $1 AND deleted_at = $2", id, deletedAt.Time)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.GetByID(ctx, id)
+}
+
+// Purge окончательно удаляет пользователей, помеченных удаленными раньше before.
+// Возвращает их количество и файлы изображений, на которые больше никто не
+// ссылается; удалить их из хранилища должен вызывающий.
+func (r *UserRepository) Purge(ctx context.Context, before time.Time) (int, []string, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id FROM users WHERE deleted_at < $1 ORDER BY id", before)
+	if err != nil {
+		return 0, nil, err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, nil, err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	purged := 0
+	var unused []string
+	for _, id := range ids {
+		ok, files, err := r.purge(ctx, id, before)
+		if err != nil {
+			return purged, unused, fmt.Errorf("failed to purge user %d: %w", id, err)
+		}
+		if ok {
+			purged++
+			unused = append(unused, files...)
+		}
+	}
+	return purged, unused, nil
+}
+
+// purge в одной транзакции удаляет пользователя вместе с его удаленными
+// объявлениями и их изображениями. Пользователь, у которого есть действующие
+// объявления (обезличенный), или успевший восстановиться, пропускается.
+func (r *UserRepository) purge(ctx context.Context, id int, before time.Time) (bool, []string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, nil, err
+	}
+	defer tx.Rollback()
+
+	var locked int
+	err = tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = $1 AND deleted_at < $2 FOR UPDATE", id, before).Scan(&locked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil, nil
+		}
+		return false, nil, err
+	}
+
+	var active bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM ads WHERE user_id = $1 AND deleted_at IS NULL)", id).Scan(&active)
+	if err != nil {
+		return false, nil, err
+	}
+	if active {
+		return false, nil, nil
+	}
+
+	unused, err := deleteUserAds(ctx, tx, id)
+	if err != nil {
+		return false, nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id); err != nil {
+		return false, nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, nil, err
+	}
+	return true, unused, nil
+}
+
+// deleteUserAds окончательно удаляет все объявления пользователя и возвращает
+// файлы изображений, на которые больше никто не ссылается
+func deleteUserAds(ctx context.Context, tx *sql.Tx, id int) ([]string, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT i.filename
+		FROM ad_images i
+		JOIN ads a ON a.id = i.ad_id
+		WHERE a.user_id = $1
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	var filenames []string
+	for rows.Next() {
+		var filename string
+		if err := rows.Scan(&filename); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		filenames = append(filenames, filename)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Изображения удалятся каскадно вместе с объявлениями
+	if _, err := tx.ExecContext(ctx, "DELETE FROM ads WHERE user_id = $1", id); err != nil {
+		return nil, err
+	}
+
+	return releaseImages(ctx, tx, filenames)
+}
+
+// Erase стирает данные пользователя по его запросу: строка пользователя
+// обезличивается, а объявления с изображениями удаляются окончательно.
+// Стереть можно и уже удаленного пользователя. Возвращает файлы изображений,
+// на которые больше никто не ссылается; удалить их должен вызывающий.
+func (r *UserRepository) Erase(ctx context.Context, id int) ([]string, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE user_roles IN SHARE ROW EXCLUSIVE MODE"); err != nil {
+		return nil, err
+	}
+
+	res, err := tx.ExecContext(ctx, "UPDATE users SET deleted_at = COALESCE(deleted_at, NOW()) WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	if updated == 0 {
+		return nil, fmt.Errorf("user with id %d does not exist", id)
+	}
+
+	admins, err := countAdmins(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	if admins == 0 {
+		return nil, fmt.Errorf("cannot delete the last administrator")
+	}
+
+	unused, err := deleteUserAds(ctx, tx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := anonymizeUser(ctx, tx, id); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE auth_sessions SET revoked_at = NOW() WHERE user_id = $1 AND revoked_at IS NULL", id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return unused, nil
+}
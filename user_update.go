@@ -0,0 +1,7 @@
+package models
+
+// UserUpdate - частичное изменение профиля; пропущенные поля не меняются
+type UserUpdate struct {
+	Name  *string `json:"name" binding:"omitempty,min=1,max=100"`
+	Email *string `json:"email" binding:"omitempty,email,max=150"`
+}
@@ -1,105 +1,470 @@
-package handlers
-
-import (
-	"fmt"
-	"log/slog"
-	"net/http"
-	"strconv"
-
-	"golang-test/internal/models"
-	"golang-test/internal/repository"
-
-	"github.com/gin-gonic/gin"
-)
-
-type UserHandler struct {
-	repo *repository.UserRepository
-}
-
-func NewUserHandler(repo *repository.UserRepository) *UserHandler {
-	return &UserHandler{repo: repo}
-}
-
-// CreateUser создает нового пользователя
-// @Summary Создать пользователя
-// @Description Создает нового пользователя в системе
-// @Tags users
-// @Accept json
-// @Produce json
-// @Param user body models.UserCreate true "Данные пользователя"
-// @Security APIKey
-// @Success 201 {object} models.User
-// @Failure 400 {object} ErrorResponse
-// @Failure 409 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
-// @Router /users [post]
-func (h *UserHandler) CreateUser(c *gin.Context) {
-	var userCreate models.UserCreate
-	if err := c.ShouldBindJSON(&userCreate); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": err.Error(),
-		})
-		return
-	}
-
-	user, err := h.repo.Create(c.Request.Context(), &userCreate)
-	if err != nil {
-		slog.Error("failed to create user", "error", err)
-		// Проверяем на дубликат email
-		if err.Error() == "pq: duplicate key value violates unique constraint \"users_email_key\"" {
-			c.JSON(http.StatusConflict, gin.H{
-				"error": "user with this email already exists",
-			})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to create user",
-		})
-		return
-	}
-
-	c.JSON(http.StatusCreated, user)
-}
-
-// DeleteUser удаляет пользователя
-// @Summary Удалить пользователя
-// @Description Удаляет пользователя по ID
-// @Tags users
-// @Accept json
-// @Produce json
-// @Param id path int true "ID пользователя"
-// @Security APIKey
-// @Success 200 {object} SuccessResponse
-// @Failure 400 {object} ErrorResponse
-// @Failure 404 {object} ErrorResponse
-// @Failure 500 {object} ErrorResponse
-// @Router /users/{id} [delete]
-func (h *UserHandler) DeleteUser(c *gin.Context) {
-	idStr := c.Param("id")
-	id, err := strconv.Atoi(idStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "invalid user id",
-		})
-		return
-	}
-
-	err = h.repo.Delete(c.Request.Context(), id)
-	if err != nil {
-		slog.Error("failed to delete user", "error", err, "id", id)
-		if err.Error() == fmt.Sprintf("user with id %d does not exist", id) {
-			c.JSON(http.StatusNotFound, gin.H{
-				"error": "user not found",
-			})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "failed to delete user",
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"status": "success",
-	})
-}
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"golang-test/internal/auth"
+	"golang-test/internal/middleware"
+	"golang-test/internal/models"
+	"golang-test/internal/repository"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultUsersLimit = 20
+	maxUsersLimit     = 100
+)
+
+type UserHandler struct {
+	repo *repository.UserRepository
+	// deletionPolicy - политика удаления, если она не указана в запросе
+	deletionPolicy string
+	// sendVerification отправляет письмо подтверждения нового email
+	// (см. AuthHandler.SendVerification)
+	sendVerification func(ctx context.Context, user *models.User) error
+}
+
+func NewUserHandler(repo *repository.UserRepository, deletionPolicy string, sendVerification func(ctx context.Context, user *models.User) error) *UserHandler {
+	return &UserHandler{repo: repo, deletionPolicy: deletionPolicy, sendVerification: sendVerification}
+}
+
+// CreateUser создает нового пользователя
+// @Summary Создать пользователя
+// @Description Создает нового пользователя в системе
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param user body models.UserCreate true "Данные пользователя"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 201 {object} models.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users [post]
+func (h *UserHandler) CreateUser(c *gin.Context) {
+	var userCreate models.UserCreate
+	if err := c.ShouldBindJSON(&userCreate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	passwordHash, err := auth.HashPassword(userCreate.Password)
+	if err != nil {
+		slog.Error("failed to hash password", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to create user",
+		})
+		return
+	}
+
+	user, err := h.repo.Create(c.Request.Context(), &userCreate, passwordHash)
+	if err != nil {
+		slog.Error("failed to create user", "error", err)
+		// Проверяем на дубликат email
+		if err.Error() == fmt.Sprintf("user with email %s already exists", userCreate.Email) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "user with this email already exists",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to create user",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, user)
+}
+
+// authorizeAccount дополнительно к authorizeUser запрещает управлять учетной
+// записью и ее данными с помощью API-ключа без области admin: иначе утекший
+// ключ ads:write позволил бы сменить email владельца, выгрузить его данные
+// или удалить аккаунт
+func authorizeAccount(c *gin.Context) (int, bool) {
+	id, ok := authorizeUser(c)
+	if !ok {
+		return 0, false
+	}
+	if middleware.CurrentAPIKeyID(c) != 0 && !middleware.CurrentAPIKeyHasScope(c, models.ScopeAdmin) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "this action requires a session token or an api key with admin scope",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// authorizeUser разрешает доступ к профилю самому пользователю
+// и пользователям с правом users:manage
+func authorizeUser(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return 0, false
+	}
+
+	user := middleware.CurrentUser(c)
+	if user.ID != id && !user.HasPermission(models.PermUsersManage) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": "you are not allowed to access this user",
+		})
+		return 0, false
+	}
+	return id, true
+}
+
+// GetUser возвращает профиль пользователя
+// @Summary Получить пользователя
+// @Description Возвращает профиль с ролями и правами. Свой профиль доступен всем, чужой - с правом users:manage
+// @Tags users
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} models.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users/{id} [get]
+func (h *UserHandler) GetUser(c *gin.Context) {
+	id, ok := authorizeUser(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.repo.GetByID(c.Request.Context(), id)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("user with id %d does not exist", id) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "user not found",
+			})
+			return
+		}
+		slog.Error("failed to get user", "error", err, "id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// GetUsers возвращает список пользователей
+// @Summary Получить список пользователей
+// @Description Возвращает страницу пользователей с ролями, упорядоченную по ID. Поиск по подстроке имени или email без учета регистра
+// @Tags users
+// @Produce json
+// @Param q query string false "Подстрока имени или email"
+// @Param page query int false "Номер страницы" default(1)
+// @Param limit query int false "Размер страницы (максимум 100)" default(20)
+// @Param deleted query bool false "Только удаленные пользователи"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} models.UserList
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users [get]
+func (h *UserHandler) GetUsers(c *gin.Context) {
+	filter, err := parseUserFilter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	users, total, err := h.repo.GetAll(c.Request.Context(), filter)
+	if err != nil {
+		slog.Error("failed to get users", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "internal server error",
+		})
+		return
+	}
+
+	pageLink := func(p int) string {
+		return linkWithQuery(c, map[string]string{
+			"page":  strconv.Itoa(p),
+			"limit": strconv.Itoa(filter.Limit),
+		})
+	}
+	links := models.PageLinks{Self: pageLink(filter.Page)}
+	if filter.Offset()+len(users) < total {
+		links.Next = pageLink(filter.Page + 1)
+	}
+	if filter.Page > 1 {
+		links.Prev = pageLink(filter.Page - 1)
+	}
+
+	c.JSON(http.StatusOK, models.UserList{
+		Items: users,
+		Total: total,
+		Page:  filter.Page,
+		Limit: filter.Limit,
+		Links: links,
+	})
+}
+
+// parseUserFilter разбирает параметры запроса списка пользователей
+func parseUserFilter(c *gin.Context) (models.UserFilter, error) {
+	filter := models.UserFilter{
+		Page:  1,
+		Limit: defaultUsersLimit,
+		Query: strings.TrimSpace(c.Query("q")),
+	}
+
+	if v := c.Query("page"); v != "" {
+		page, err := strconv.Atoi(v)
+		if err != nil || page < 1 {
+			return filter, fmt.Errorf("invalid page")
+		}
+		filter.Page = page
+	}
+
+	if v := c.Query("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxUsersLimit {
+			return filter, fmt.Errorf("limit must be between 1 and %d", maxUsersLimit)
+		}
+		filter.Limit = limit
+	}
+
+	if v := c.Query("deleted"); v != "" {
+		deleted, err := strconv.ParseBool(v)
+		if err != nil {
+			return filter, fmt.Errorf("invalid deleted")
+		}
+		filter.Deleted = deleted
+	}
+
+	return filter, nil
+}
+
+// UpdateUser изменяет профиль пользователя
+// @Summary Изменить пользователя
+// @Description Меняет имя и/или email. Свой профиль может менять каждый, чужой - с правом users:manage. После смены email адрес считается неподтвержденным, на новый адрес отправляется письмо для подтверждения
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param user body models.UserUpdate true "Новые значения полей"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} models.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users/{id} [patch]
+func (h *UserHandler) UpdateUser(c *gin.Context) {
+	id, ok := authorizeAccount(c)
+	if !ok {
+		return
+	}
+
+	var update models.UserUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	if update.Name == nil && update.Email == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "nothing to update",
+		})
+		return
+	}
+
+	user, err := h.repo.Update(c.Request.Context(), id, &update)
+	if err != nil {
+		if err.Error() == fmt.Sprintf("user with id %d does not exist", id) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "user not found",
+			})
+			return
+		}
+		if update.Email != nil && err.Error() == fmt.Sprintf("user with email %s already exists", *update.Email) {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "user with this email already exists",
+			})
+			return
+		}
+		slog.Error("failed to update user", "error", err, "id", id)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "failed to update user",
+		})
+		return
+	}
+
+	// Новый адрес не подтвержден: отправляем на него ссылку, как при регистрации.
+	// Изменение не откатывается из-за почты: письмо можно запросить повторно.
+	if update.Email != nil && user.EmailVerifiedAt == nil {
+		if err := h.sendVerification(c.Request.Context(), user); err != nil {
+			slog.Error("failed to send verification email", "error", err, "user_id", user.ID)
+		}
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// DeleteUser удаляет пользователя
+// @Summary Удалить пользователя
+// @Description Помечает пользователя удаленным и завершает его сессии. Объявления обрабатываются по политике: cascade - удаляются вместе с пользователем, reassign - передаются пользователю reassign_to, block - удаление запрещено, пока есть объявления (409 со списком), anonymize - данные пользователя стираются, объявления остаются. По умолчанию используется USER_DELETION_POLICY. До окончательной очистки пользователя можно восстановить, кроме обезличенного
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Param policy query string false "Политика удаления" Enums(cascade, reassign, block, anonymize)
+// @Param reassign_to query int false "Новый владелец объявлений для политики reassign"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users/{id} [delete]
+func (h *UserHandler) DeleteUser(c *gin.Context) {
+	idStr := c.Param("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	deletion, ok := h.parseUserDeletion(c)
+	if !ok {
+		return
+	}
+
+	err = h.repo.Delete(c.Request.Context(), id, deletion)
+	if err != nil {
+		var hasAds *repository.UserHasAdsError
+		switch {
+		case errors.As(err, &hasAds):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "user has ads",
+				"ads":   hasAds.Ads,
+			})
+		case err.Error() == fmt.Sprintf("user with id %d does not exist", id):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "user not found",
+			})
+		case err.Error() == fmt.Sprintf("reassign target user with id %d does not exist", deletion.ReassignTo):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "invalid reassign_to user",
+			})
+		case err.Error() == "cannot delete the last administrator":
+			c.JSON(http.StatusConflict, gin.H{
+				"error": err.Error(),
+			})
+		default:
+			slog.Error("failed to delete user", "error", err, "id", id)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to delete user",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "success",
+	})
+}
+
+// parseUserDeletion читает политику удаления из query-параметров.
+// При ошибке ответ клиенту отправляется здесь же.
+func (h *UserHandler) parseUserDeletion(c *gin.Context) (models.UserDeletion, bool) {
+	deletion := models.UserDeletion{Policy: c.DefaultQuery("policy", h.deletionPolicy)}
+	if !models.IsDeletionPolicy(deletion.Policy) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "policy must be one of: " + strings.Join(models.DeletionPolicies, ", "),
+		})
+		return deletion, false
+	}
+
+	if deletion.Policy != models.DeletionReassign {
+		return deletion, true
+	}
+	to, err := strconv.Atoi(c.Query("reassign_to"))
+	if err != nil || to < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "reassign_to is required for reassign policy",
+		})
+		return deletion, false
+	}
+	deletion.ReassignTo = to
+	return deletion, true
+}
+
+// RestoreUser восстанавливает удаленного пользователя
+// @Summary Восстановить пользователя
+// @Description Снимает пометку удаления с пользователя и объявлений, удаленных вместе с ним. Сессии не восстанавливаются
+// @Tags users
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Security BearerAuth
+// @Security APIKey
+// @Success 200 {object} models.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users/{id}/restore [post]
+func (h *UserHandler) RestoreUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "invalid user id",
+		})
+		return
+	}
+
+	user, err := h.repo.Restore(c.Request.Context(), id)
+	if err != nil {
+		switch {
+		case err.Error() == fmt.Sprintf("user with id %d does not exist", id):
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "user not found",
+			})
+		case err.Error() == fmt.Sprintf("user with id %d is not deleted", id):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "user is not deleted",
+			})
+		case err.Error() == fmt.Sprintf("user with id %d is anonymized", id):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "anonymized user cannot be restored",
+			})
+		case strings.HasPrefix(err.Error(), "user with email "):
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "user with this email already exists",
+			})
+		default:
+			slog.Error("failed to restore user", "error", err, "id", id)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "failed to restore user",
+			})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}